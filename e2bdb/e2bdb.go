@@ -2,6 +2,7 @@ package e2bdb
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"errors"
 	"io"
@@ -108,6 +109,19 @@ func (b *BDB) Close() error {
 	return b.bdb.Close()
 }
 
+// Ping fail once the database is closed, it is an e2health.Checker
+//
+//	e2health.Register("bdb", bdb, e2health.DefaultCheckOptions().WithLiveness(true))
+func (b *BDB) Ping(ctx context.Context) error {
+	if b.bdb.IsClosed() {
+		return errors.New("badger: database is closed")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return b.bdb.View(func(txn *badger.Txn) error { return nil })
+}
+
 func (b *BDB) StorageFile(f *File, opts ...Options) error {
 	opt := DefaultOptions()
 	if len(opts) > 0 {
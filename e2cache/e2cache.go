@@ -67,6 +67,8 @@ type Connect struct {
 	Enable bool
 	Err    error
 	*cache.Cache[any]
+	redis *redis.Client
+	stats *statsStore
 }
 
 func New(cfg *Config) *Connect {
@@ -81,16 +83,40 @@ func New(cfg *Config) *Connect {
 			return c
 		}
 		cli := redis.NewClient(opts)
+		c.redis = cli
 		redisStore := redisstore.NewRedis(cli)
-		c.Cache = cache.New[any](redisStore)
+		c.Cache = c.newCache(redisStore)
 	case "memory":
 		logrus.Infof("using memory cache")
 		goCache := gocachestore.NewGoCache(gocache.New(gocache.NoExpiration, gocache.NoExpiration))
-		c.Cache = cache.New[any](goCache)
+		c.Cache = c.newCache(goCache)
 	default:
 		logrus.Infof("using fake cache")
-		c.Cache = cache.New[any](NewFakeCacheStore[any]())
+		c.Cache = c.newCache(NewFakeCacheStore[any]())
 	}
 
 	return c
 }
+
+func (c *Connect) newCache(s store.StoreInterface) *cache.Cache[any] {
+	c.stats = &statsStore{StoreInterface: s}
+	return cache.New[any](c.stats)
+}
+
+// Redis return the client of a redis cache, nil for other types
+func (c *Connect) Redis() *redis.Client {
+	return c.redis
+}
+
+// Ping report the connection error, redis caches are pinged, it is an e2health.Checker
+//
+//	e2health.Register("cache", conn)
+func (c *Connect) Ping(ctx context.Context) error {
+	if c.Err != nil {
+		return c.Err
+	}
+	if c.redis != nil {
+		return c.redis.Ping(ctx).Err()
+	}
+	return nil
+}
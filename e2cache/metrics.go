@@ -0,0 +1,87 @@
+package e2cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/eko/gocache/lib/v4/store"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statsStore count the lookups of the wrapped store
+type statsStore struct {
+	store.StoreInterface
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+func (s *statsStore) Get(ctx context.Context, key any) (any, error) {
+	v, err := s.StoreInterface.Get(ctx, key)
+	s.count(err)
+	return v, err
+}
+
+func (s *statsStore) GetWithTTL(ctx context.Context, key any) (any, time.Duration, error) {
+	v, ttl, err := s.StoreInterface.GetWithTTL(ctx, key)
+	s.count(err)
+	return v, ttl, err
+}
+
+func (s *statsStore) count(err error) {
+	switch {
+	case err == nil:
+		s.hits.Add(1)
+	case errors.Is(err, store.NotFound{}):
+		s.misses.Add(1)
+	default:
+		s.errors.Add(1)
+	}
+}
+
+// Stats of the lookups since New
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	Errors uint64
+}
+
+func (c *Connect) Stats() Stats {
+	if c.stats == nil {
+		return Stats{}
+	}
+	return Stats{Hits: c.stats.hits.Load(), Misses: c.stats.misses.Load(), Errors: c.stats.errors.Load()}
+}
+
+type collector struct {
+	conn   *Connect
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+	errors *prometheus.Desc
+}
+
+// Collector export the hit, miss and error counts labelled cache=name, e.g. for e2gin.MetricsOption
+func (c *Connect) Collector(name string) prometheus.Collector {
+	labels := prometheus.Labels{"cache": name}
+	return &collector{
+		conn:   c,
+		hits:   prometheus.NewDesc("cache_hits_total", "Cache lookups that found a value.", nil, labels),
+		misses: prometheus.NewDesc("cache_misses_total", "Cache lookups that found nothing.", nil, labels),
+		errors: prometheus.NewDesc("cache_errors_total", "Cache lookups that failed.", nil, labels),
+	}
+}
+
+func (c *collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.errors
+}
+
+func (c *collector) Collect(ch chan<- prometheus.Metric) {
+	st := c.conn.Stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(st.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(st.Misses))
+	ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(st.Errors))
+}
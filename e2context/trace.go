@@ -0,0 +1,122 @@
+package e2context
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+const (
+	RequestIDHeader   = "X-Request-Id"
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+
+	// TraceKey also find the Trace in a context storing values under string keys, e.g. a
+	// *gin.Context where the middleware did c.Set(e2context.TraceKey, trace)
+	TraceKey = "e2context.trace"
+)
+
+var ErrInvalidTraceParent = errors.New("e2context: invalid traceparent")
+
+// TraceParent is a W3C trace context traceparent, https://www.w3.org/TR/trace-context/
+type TraceParent struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// NewTraceParent start a sampled trace with a random trace and span id
+func NewTraceParent() TraceParent {
+	var tp TraceParent
+	_, _ = rand.Read(tp.TraceID[:])
+	_, _ = rand.Read(tp.SpanID[:])
+	tp.Flags = 0x01
+	return tp
+}
+
+// ParseTraceParent parse "version-traceid-spanid-flags", versions above 00 are read the 00 way
+func ParseTraceParent(s string) (TraceParent, error) {
+	var tp TraceParent
+	s = strings.TrimSpace(s)
+	parts := strings.Split(s, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return tp, ErrInvalidTraceParent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 || strings.ToLower(s) != s {
+		return tp, ErrInvalidTraceParent
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(tp.TraceID[:], []byte(parts[1])); err != nil {
+		return tp, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(tp.SpanID[:], []byte(parts[2])); err != nil {
+		return tp, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return tp, ErrInvalidTraceParent
+	}
+	tp.Flags = flags[0]
+	if !tp.IsValid() {
+		return tp, ErrInvalidTraceParent
+	}
+	return tp, nil
+}
+
+// IsValid report whether neither id is all zeros
+func (tp TraceParent) IsValid() bool {
+	return tp.TraceID != [16]byte{} && tp.SpanID != [8]byte{}
+}
+
+func (tp TraceParent) Sampled() bool {
+	return tp.Flags&0x01 != 0
+}
+
+// Child keep the trace id and flags with a new span id, for the next hop
+func (tp TraceParent) Child() TraceParent {
+	_, _ = rand.Read(tp.SpanID[:])
+	return tp
+}
+
+func (tp TraceParent) TraceIDString() string {
+	return hex.EncodeToString(tp.TraceID[:])
+}
+
+func (tp TraceParent) SpanIDString() string {
+	return hex.EncodeToString(tp.SpanID[:])
+}
+
+func (tp TraceParent) String() string {
+	return "00-" + tp.TraceIDString() + "-" + tp.SpanIDString() + "-" + hex.EncodeToString([]byte{tp.Flags})
+}
+
+// Trace correlate the logs and outbound calls of one request
+type Trace struct {
+	RequestID   string
+	TraceParent TraceParent // SpanID is the span of this service
+	TraceState  string      // forwarded as is
+}
+
+type traceKey struct{}
+
+func WithTrace(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+func TraceFrom(ctx context.Context) (Trace, bool) {
+	if ctx == nil {
+		return Trace{}, false
+	}
+	if t, ok := ctx.Value(traceKey{}).(Trace); ok {
+		return t, true
+	}
+	t, ok := ctx.Value(TraceKey).(Trace)
+	return t, ok
+}
+
+// RequestID return the request id of ctx, empty when there is none
+func RequestID(ctx context.Context) string {
+	t, _ := TraceFrom(ctx)
+	return t.RequestID
+}
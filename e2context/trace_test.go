@@ -0,0 +1,50 @@
+package e2context
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	tp, err := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", tp.TraceIDString())
+	assert.Equal(t, "00f067aa0ba902b7", tp.SpanIDString())
+	assert.True(t, tp.Sampled())
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", tp.String())
+
+	child := tp.Child()
+	assert.Equal(t, tp.TraceID, child.TraceID)
+	assert.NotEqual(t, tp.SpanID, child.SpanID)
+
+	// a later version may append fields
+	_, err = ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	assert.NoError(t, err)
+
+	for _, s := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		_, err := ParseTraceParent(s)
+		assert.ErrorIs(t, err, ErrInvalidTraceParent, s)
+	}
+}
+
+func TestTraceFrom(t *testing.T) {
+	_, ok := TraceFrom(context.TODO())
+	assert.False(t, ok)
+
+	ctx := WithTrace(context.TODO(), Trace{RequestID: "r1", TraceParent: NewTraceParent()})
+	assert.Equal(t, "r1", RequestID(ctx))
+	tr, ok := TraceFrom(ctx)
+	assert.True(t, ok)
+	assert.True(t, tr.TraceParent.IsValid())
+}
@@ -1,6 +1,7 @@
 package e2db
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	"math/big"
@@ -11,6 +12,8 @@ import (
 	"github.com/e2u/e2util/e2model"
 	"github.com/e2u/e2util/e2regexp"
 	"github.com/glebarez/sqlite"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -220,6 +223,47 @@ func (c *Connect) Patch(v interface{}, patchs []*e2model.HttpPatch) error {
 	return c.RW().Model(v).Updates(updates).Error
 }
 
+// Ping check the writer and every reader, it is an e2health.Checker
+//
+//	e2health.Register("db", conn)
+func (c *Connect) Ping(ctx context.Context) error {
+	dbs := append([]*gorm.DB{c.db}, c.roDb...)
+	for i, db := range dbs {
+		if i > 0 && db == c.db {
+			continue
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			if i > 0 {
+				return fmt.Errorf("reader %d: %w", i-1, err)
+			}
+			return fmt.Errorf("writer: %w", err)
+		}
+	}
+	return nil
+}
+
+// Collectors export the connection pool stats of the writer as db_name=name and of each reader
+// as name_ro<N>, e.g. for e2gin.MetricsOption
+func (c *Connect) Collectors(name string) []prometheus.Collector {
+	var cs []prometheus.Collector
+	if sqlDB, err := c.db.DB(); err == nil {
+		cs = append(cs, collectors.NewDBStatsCollector(sqlDB, name))
+	}
+	for i, db := range c.roDb {
+		if db == c.db {
+			continue
+		}
+		if sqlDB, err := db.DB(); err == nil {
+			cs = append(cs, collectors.NewDBStatsCollector(sqlDB, fmt.Sprintf("%s_ro%d", name, i)))
+		}
+	}
+	return cs
+}
+
 func (c *Connect) DebugRW() *gorm.DB {
 	return c.RW().Debug()
 }
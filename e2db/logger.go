@@ -1,12 +1,16 @@
 package e2db
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/e2u/e2util/e2logrus"
 	"github.com/sirupsen/logrus"
 	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
 )
 
 func newLogger(cfg *Config) gormlogger.Interface {
@@ -37,14 +41,109 @@ func newLogger(cfg *Config) gormlogger.Interface {
 
 	dbLogger.AddHook(&e2logrus.SeqHook{})
 	SQLLogColorful := cfg.SQLLogColorful
-	if cfg.LoggerConfig.Format == "json" {
+	if cfg.LoggerConfig != nil && cfg.LoggerConfig.Format == "json" {
 		SQLLogColorful = false
 	}
 
-	return gormlogger.New(dbLogger, gormlogger.Config{
+	return newContextLogger(dbLogger, gormlogger.Config{
 		SlowThreshold:             time.Duration(cfg.SQLLogSlowThreshold) * time.Millisecond,
 		LogLevel:                  ll,
 		IgnoreRecordNotFoundError: cfg.SQLLogIgnoreRecordNotFoundError,
 		Colorful:                  SQLLogColorful,
 	})
 }
+
+// contextLogger is gorm's logger writing through an entry carrying the statement context, so hooks
+// like e2logrus.TraceHook see the request of db.WithContext(ctx). gorm's Writer has no context,
+// so the formats are built once here and every call only binds its context to an entry
+type contextLogger struct {
+	log *logrus.Logger
+	cfg gormlogger.Config
+
+	infoStr, warnStr, errStr            string
+	traceStr, traceWarnStr, traceErrStr string
+}
+
+// newContextLogger use the same formats as gormlogger.New
+func newContextLogger(log *logrus.Logger, cfg gormlogger.Config) *contextLogger {
+	l := &contextLogger{
+		log:          log,
+		cfg:          cfg,
+		infoStr:      "%s\n[info] ",
+		warnStr:      "%s\n[warn] ",
+		errStr:       "%s\n[error] ",
+		traceStr:     "%s\n[%.3fms] [rows:%v] %s",
+		traceWarnStr: "%s %s\n[%.3fms] [rows:%v] %s",
+		traceErrStr:  "%s %s\n[%.3fms] [rows:%v] %s",
+	}
+	if cfg.Colorful {
+		l.infoStr = gormlogger.Green + "%s\n" + gormlogger.Reset + gormlogger.Green + "[info] " + gormlogger.Reset
+		l.warnStr = gormlogger.BlueBold + "%s\n" + gormlogger.Reset + gormlogger.Magenta + "[warn] " + gormlogger.Reset
+		l.errStr = gormlogger.Magenta + "%s\n" + gormlogger.Reset + gormlogger.Red + "[error] " + gormlogger.Reset
+		l.traceStr = gormlogger.Green + "%s\n" + gormlogger.Reset + gormlogger.Yellow + "[%.3fms] " + gormlogger.BlueBold + "[rows:%v]" + gormlogger.Reset + " %s"
+		l.traceWarnStr = gormlogger.Green + "%s " + gormlogger.Yellow + "%s\n" + gormlogger.Reset + gormlogger.RedBold + "[%.3fms] " + gormlogger.Yellow + "[rows:%v]" + gormlogger.Magenta + " %s" + gormlogger.Reset
+		l.traceErrStr = gormlogger.RedBold + "%s " + gormlogger.MagentaBold + "%s\n" + gormlogger.Reset + gormlogger.Yellow + "[%.3fms] " + gormlogger.BlueBold + "[rows:%v]" + gormlogger.Reset + " %s"
+	}
+	return l
+}
+
+func (l *contextLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	nl := *l
+	nl.cfg.LogLevel = level
+	return &nl
+}
+
+// Info like the other log methods calls utils.FileWithLineNum directly, so the reported line is
+// the first caller outside gorm and not this file
+func (l *contextLogger) Info(ctx context.Context, msg string, data ...any) {
+	if l.cfg.LogLevel >= gormlogger.Info {
+		l.log.WithContext(ctx).Printf(l.infoStr+msg, append([]any{utils.FileWithLineNum()}, data...)...)
+	}
+}
+
+func (l *contextLogger) Warn(ctx context.Context, msg string, data ...any) {
+	if l.cfg.LogLevel >= gormlogger.Warn {
+		l.log.WithContext(ctx).Printf(l.warnStr+msg, append([]any{utils.FileWithLineNum()}, data...)...)
+	}
+}
+
+func (l *contextLogger) Error(ctx context.Context, msg string, data ...any) {
+	if l.cfg.LogLevel >= gormlogger.Error {
+		l.log.WithContext(ctx).Printf(l.errStr+msg, append([]any{utils.FileWithLineNum()}, data...)...)
+	}
+}
+
+func (l *contextLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.cfg.LogLevel <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	ms := float64(elapsed.Nanoseconds()) / 1e6
+	switch {
+	case err != nil && l.cfg.LogLevel >= gormlogger.Error && (!errors.Is(err, gormlogger.ErrRecordNotFound) || !l.cfg.IgnoreRecordNotFoundError):
+		sql, rows := fc()
+		l.log.WithContext(ctx).Printf(l.traceErrStr, utils.FileWithLineNum(), err, ms, rowsString(rows), sql)
+	case elapsed > l.cfg.SlowThreshold && l.cfg.SlowThreshold != 0 && l.cfg.LogLevel >= gormlogger.Warn:
+		sql, rows := fc()
+		slowLog := fmt.Sprintf("SLOW SQL >= %v", l.cfg.SlowThreshold)
+		l.log.WithContext(ctx).Printf(l.traceWarnStr, utils.FileWithLineNum(), slowLog, ms, rowsString(rows), sql)
+	case l.cfg.LogLevel == gormlogger.Info:
+		sql, rows := fc()
+		l.log.WithContext(ctx).Printf(l.traceStr, utils.FileWithLineNum(), ms, rowsString(rows), sql)
+	}
+}
+
+// ParamsFilter keep gorm's ParameterizedQueries working
+func (l *contextLogger) ParamsFilter(ctx context.Context, sql string, params ...any) (string, []any) {
+	if l.cfg.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
+}
+
+func rowsString(rows int64) any {
+	if rows == -1 {
+		return "-"
+	}
+	return rows
+}
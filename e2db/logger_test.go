@@ -0,0 +1,43 @@
+package e2db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/e2u/e2util/e2context"
+	"github.com/e2u/e2util/e2logrus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&buf)
+	log.SetFormatter(&logrus.JSONFormatter{})
+	log.AddHook(&e2logrus.TraceHook{})
+	l := newContextLogger(log, gormlogger.Config{LogLevel: gormlogger.Info})
+
+	ctx := e2context.WithTrace(context.TODO(), e2context.Trace{RequestID: "req-1", TraceParent: e2context.NewTraceParent()})
+	l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 1", 1 }, nil)
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Contains(t, entry["msg"], "[rows:1] SELECT 1")
+	assert.Contains(t, entry["msg"], "logger_test.go")
+
+	buf.Reset()
+	l.Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 2", -1 }, errors.New("boom"))
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Contains(t, entry["msg"], "boom")
+	assert.Contains(t, entry["msg"], "[rows:-] SELECT 2")
+
+	buf.Reset()
+	l.LogMode(gormlogger.Silent).Trace(ctx, time.Now(), func() (string, int64) { return "SELECT 3", 1 }, nil)
+	assert.Empty(t, buf.String())
+}
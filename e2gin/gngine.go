@@ -14,15 +14,14 @@ import (
 	_ "net/http/pprof"
 	"net/url"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/e2u/e2util/e2exec"
+	"github.com/e2u/e2util/e2health"
 	h "github.com/e2u/e2util/e2html"
 	"github.com/e2u/e2util/e2io"
 	"github.com/e2u/e2util/e2os"
@@ -46,6 +45,8 @@ type Option struct {
 	DisableRecovery        bool
 	SkipLogPaths           []string
 	HealthPathPrefix       string
+	Health                 *e2health.Registry // checks of livez and readyz under HealthPathPrefix, default e2health.Default
+	Metrics                *MetricsOption     // request metrics and the /metrics endpoint, off when nil
 	Engine                 *gin.Engine
 	NoRouteProxyBackendURL string
 	DisableGzip            bool
@@ -113,6 +114,10 @@ func DefaultEngine(opt *Option) *gin.Engine {
 		eng.Use(ginrus.Ginrus(opt.LogrusLogger, time.RFC3339Nano, false))
 	}
 
+	if opt.Metrics != nil {
+		startMetrics(eng, opt)
+	}
+
 	if !opt.DisableHealth {
 		if opt.HealthPathPrefix == "" {
 			opt.HealthPathPrefix = "/__app"
@@ -124,11 +129,27 @@ func DefaultEngine(opt *Option) *gin.Engine {
 				SkipPaths: []string{opt.Root + "/_health", "/_health"},
 			}))
 
+			if opt.Health == nil {
+				opt.Health = e2health.Default
+			}
+			hg.GET(opt.HealthPathPrefix+"/livez", healthReport(opt.Health, true))
+			hg.HEAD(opt.HealthPathPrefix+"/livez", healthReport(opt.Health, true))
+			hg.GET(opt.HealthPathPrefix+"/readyz", healthReport(opt.Health, false))
+			hg.HEAD(opt.HealthPathPrefix+"/readyz", healthReport(opt.Health, false))
+
 			hg.GET(opt.HealthPathPrefix+"/_health", func(c *gin.Context) {
+				if !Ready() {
+					c.String(http.StatusServiceUnavailable, "DRAINING")
+					return
+				}
 				c.String(http.StatusOK, "OK")
 			})
 
 			hg.HEAD(opt.HealthPathPrefix+"/_health", func(c *gin.Context) {
+				if !Ready() {
+					c.Status(http.StatusServiceUnavailable)
+					return
+				}
 				c.Status(http.StatusOK)
 			})
 		}
@@ -207,6 +228,31 @@ func loadIndexPage(sfs []*StaticFiles) []byte {
 	return nil
 }
 
+// healthReport answer 200 or 503 with the result of each check, readyz also fails while draining
+func healthReport(reg *e2health.Registry, live bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var report e2health.Report
+		if live {
+			report = reg.Live(c.Request.Context())
+		} else {
+			report = reg.Ready(c.Request.Context())
+			if !Ready() {
+				report.Status = "draining"
+			}
+		}
+		code := http.StatusOK
+		if !report.OK() {
+			code = http.StatusServiceUnavailable
+		}
+		c.Header("Cache-Control", "no-store")
+		if c.Request.Method == http.MethodHead {
+			c.Status(code)
+			return
+		}
+		c.JSON(code, report)
+	}
+}
+
 func startPprof(eng *gin.Engine, opt *Option) {
 	if opt.PprofPathPrefix == "" {
 		opt.PprofPathPrefix = cleanHttpPath("/__app")
@@ -295,18 +341,16 @@ func hostPortActive(host string) bool {
 	return false
 }
 
+// StartAndStopHttp serve eng on address:port until SIGINT or SIGTERM, drain it, call stop and exit,
+// use NewServer for TLS, HTTP/2 and timeouts
 func StartAndStopHttp(eng *gin.Engine, address string, port int, stop func()) {
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		logrus.Infof("Server started. Press Ctrl+C to stop.")
-		if err := eng.Run(fmt.Sprintf("%s:%d", address, port)); err != nil {
-			logrus.Fatal(err)
-		}
-	}()
-	<-sigChan
-	fmt.Println("Received SIGINT or SIGTERM. Shutting down...")
-	stop()
+	srv, err := NewServer(eng, ServerOption{Address: address, Port: port})
+	if err == nil {
+		err = srv.Run(stop)
+	}
+	if err != nil {
+		logrus.Fatal(err)
+	}
 	os.Exit(0)
 }
 
@@ -1,15 +1,22 @@
 package e2gin
 
 import (
+	"context"
 	"embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/e2u/e2util/e2exec"
+	"github.com/e2u/e2util/e2health"
 	"github.com/gin-contrib/cors"
+	"github.com/stretchr/testify/assert"
 )
 
 var (
@@ -60,3 +67,38 @@ func TestDefaultEngine(t *testing.T) {
 	apiGroup := r.Group("/api/v1")
 	_ = apiGroup
 }
+
+func TestHealthEndpoints(t *testing.T) {
+	defer SetReady(true)
+
+	reg := e2health.NewRegistry(0)
+	reg.Register("db", e2health.CheckFunc(func(ctx context.Context) error { return nil }))
+	eng := DefaultEngine(&Option{DisabledPprof: true, DisableGzip: true, Health: reg})
+
+	get := func(path string) (int, e2health.Report) {
+		w := httptest.NewRecorder()
+		eng.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var report e2health.Report
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		return w.Code, report
+	}
+
+	code, report := get("/__app/readyz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, e2health.StatusOK, report.Checks["db"].Status)
+
+	reg.Register("cache", e2health.CheckFunc(func(ctx context.Context) error { return errors.New("down") }))
+	code, report = get("/__app/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "down", report.Checks["cache"].Error)
+
+	code, report = get("/__app/livez")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, report.Checks)
+
+	SetReady(false)
+	reg.Unregister("cache")
+	code, report = get("/__app/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", report.Status)
+}
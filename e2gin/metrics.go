@@ -0,0 +1,112 @@
+package e2gin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsOption turn on the request metrics of DefaultEngine and the /metrics endpoint
+//
+//	opt.Metrics = &e2gin.MetricsOption{
+//		Collectors: append(db.Collectors("main"), cache.Collector("main")),
+//	}
+type MetricsOption struct {
+	Registry   *prometheus.Registry // default a new registry with the Go and process collectors
+	Namespace  string               // prefix of the metric names, e.g. myapp_http_requests_total
+	Buckets    []float64            // latency buckets in seconds, default prometheus.DefBuckets
+	Path       string               // default PprofPathPrefix + "/metrics"
+	Collectors []prometheus.Collector
+}
+
+type httpMetrics struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	inFlight *prometheus.GaugeVec
+}
+
+// route label of requests without a matching route, the raw path would explode the cardinality
+const unmatchedRoute = "<unmatched>"
+
+func newHttpMetrics(opt *MetricsOption) *httpMetrics {
+	if opt.Registry == nil {
+		opt.Registry = prometheus.NewRegistry()
+		opt.Registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+	if len(opt.Buckets) == 0 {
+		opt.Buckets = prometheus.DefBuckets
+	}
+
+	m := &httpMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opt.Namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status.",
+		}, []string{"route", "method", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opt.Namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template, method and status.",
+			Buckets:   opt.Buckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: opt.Namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being served by route template and method.",
+		}, []string{"route", "method"}),
+	}
+	opt.Registry.MustRegister(m.requests, m.latency, m.inFlight)
+	opt.Registry.MustRegister(opt.Collectors...)
+	return m
+}
+
+func (m *httpMetrics) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+
+		start := time.Now()
+		inFlight := m.inFlight.WithLabelValues(route, method)
+		inFlight.Inc()
+		defer func() {
+			inFlight.Dec()
+			// without DefaultEngine's recovery a panic unwinds past here before any status was written
+			status := c.Writer.Status()
+			p := recover()
+			if p != nil {
+				status = http.StatusInternalServerError
+			}
+			code := strconv.Itoa(status)
+			m.requests.WithLabelValues(route, method, code).Inc()
+			m.latency.WithLabelValues(route, method, code).Observe(time.Since(start).Seconds())
+			if p != nil {
+				panic(p)
+			}
+		}()
+		c.Next()
+	}
+}
+
+func startMetrics(eng *gin.Engine, opt *Option) {
+	m := newHttpMetrics(opt.Metrics)
+	eng.Use(m.middleware())
+
+	if opt.Metrics.Path == "" {
+		prefix := opt.PprofPathPrefix
+		if prefix == "" {
+			prefix = cleanHttpPath("/__app")
+		}
+		opt.Metrics.Path = prefix + "/metrics"
+	}
+	eng.GET(opt.Metrics.Path, gin.WrapH(promhttp.HandlerFor(opt.Metrics.Registry, promhttp.HandlerOpts{
+		Registry: opt.Metrics.Registry,
+	})))
+}
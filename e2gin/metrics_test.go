@@ -0,0 +1,46 @@
+package e2gin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/e2u/e2util/e2cache"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	cache := e2cache.New(&e2cache.Config{Type: "memory"})
+	_, _ = cache.Get(context.TODO(), "missing")
+	_ = cache.Set(context.TODO(), "k", "v")
+	_, _ = cache.Get(context.TODO(), "k")
+
+	eng := DefaultEngine(&Option{
+		DisabledPprof: true,
+		DisableGzip:   true,
+		Metrics:       &MetricsOption{Namespace: "app", Collectors: []prometheus.Collector{cache.Collector("main")}},
+	})
+	eng.GET("/users/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("id"))
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/nope"} {
+		eng.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/__app/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `app_http_requests_total{method="GET",route="/users/:id",status="200"} 2`)
+	assert.Contains(t, body, `app_http_requests_total{method="GET",route="<unmatched>",status="404"} 1`)
+	assert.Contains(t, body, `app_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`)
+	assert.Contains(t, body, `app_http_requests_in_flight{method="GET",route="/users/:id"} 0`)
+	assert.NotContains(t, body, "/users/1")
+	assert.Contains(t, body, `cache_hits_total{cache="main"} 1`)
+	assert.Contains(t, body, `cache_misses_total{cache="main"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
@@ -39,7 +39,8 @@ func RequestLoggingMiddleware(logger *logrus.Logger) gin.HandlerFunc {
 			panic(fmt.Errorf("err while marshaling req msg: %w", err))
 		}
 		ctx.Next()
-		logger.WithFields(logrus.Fields{
+		// the request context carries the e2context.Trace for e2logrus.TraceHook
+		logger.WithContext(ctx.Request.Context()).WithFields(logrus.Fields{
 			"status":       ctx.Writer.Status(),
 			"method":       ctx.Request.Method,
 			"path":         ctx.Request.URL.Path,
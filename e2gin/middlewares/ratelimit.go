@@ -0,0 +1,187 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/e2u/e2util/e2cache"
+	"github.com/e2u/e2util/e2gin/resp"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type RateLimitAlgorithm int
+
+const (
+	// SlidingWindow count the requests of the current and previous window, the previous one
+	// weighted by how much of it still overlaps the sliding window
+	SlidingWindow RateLimitAlgorithm = iota
+	// TokenBucket refill Limit tokens per Window up to Limit, so bursts up to Limit are allowed
+	TokenBucket
+)
+
+// RateLimitResult of taking one request from a key
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the quota is fully available again
+	RetryAfter time.Duration // until the next request is allowed, zero when Allowed
+}
+
+// RateLimitStore keep the counters of the keys, see NewMemoryRateLimitStore and NewRedisRateLimitStore
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, alg RateLimitAlgorithm, limit int, window time.Duration) (RateLimitResult, error)
+}
+
+type RateLimitConfig struct {
+	Limit     int           // requests per Window
+	Window    time.Duration // default 1 minute
+	Algorithm RateLimitAlgorithm
+	// KeyFunc group the requests sharing a quota, an empty key is not limited, default ClientIPKey
+	KeyFunc func(c *gin.Context) string
+	Store   RateLimitStore // default NewMemoryRateLimitStore()
+	Prefix  string         // prefix of the store keys, default "ratelimit:"
+	// DenyOnError answer 429 when the store fails, by default the request goes through
+	DenyOnError bool
+}
+
+// ClientIPKey limit by gin's ClientIP, mind gin.Engine.SetTrustedProxies behind a proxy
+func ClientIPKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// HeaderKey limit by the value of header, e.g. HeaderKey("X-Api-Key"), requests without it fall
+// back to ClientIPKey, the value is hashed so secrets are not stored
+func HeaderKey(header string) func(c *gin.Context) string {
+	return func(c *gin.Context) string {
+		v := c.GetHeader(header)
+		if v == "" {
+			return ClientIPKey(c)
+		}
+		sum := sha256.Sum256([]byte(v))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+}
+
+// RateLimitMiddleware reject requests over the quota of their key with 429, every limited response
+// carries RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+//
+//	cache := e2cache.New(cfg.Cache)
+//	r.Use(middlewares.RateLimitMiddleware(middlewares.RateLimitConfig{
+//		Limit:   100,
+//		Window:  time.Minute,
+//		KeyFunc: middlewares.HeaderKey("X-Api-Key"),
+//		Store:   middlewares.NewRateLimitStore(cache),
+//	}))
+func RateLimitMiddleware(cfg RateLimitConfig) gin.HandlerFunc {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = ClientIPKey
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryRateLimitStore()
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "ratelimit:"
+	}
+	policy := fmt.Sprintf("%d;w=%d", cfg.Limit, int(math.Ceil(cfg.Window.Seconds())))
+
+	return func(c *gin.Context) {
+		key := cfg.KeyFunc(c)
+		if key == "" || cfg.Limit <= 0 {
+			c.Next()
+			return
+		}
+
+		res, err := cfg.Store.Allow(c.Request.Context(), cfg.Prefix+key, cfg.Algorithm, cfg.Limit, cfg.Window)
+		if err != nil {
+			logrus.Errorf("rate limit store error=%v", err)
+			if !cfg.DenyOnError {
+				c.Next()
+				return
+			}
+			res = RateLimitResult{Limit: cfg.Limit, Reset: cfg.Window, RetryAfter: cfg.Window}
+		}
+
+		c.Header("RateLimit-Policy", policy)
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		if !res.Allowed {
+			retryAfter := ceilSeconds(res.RetryAfter)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			resp.AboutWithJSON(c, resp.TooManyRequests, gin.H{"retry_after": retryAfter})
+			return
+		}
+		c.Next()
+	}
+}
+
+// NewRateLimitStore keep the counters in the redis of an e2cache redis connection, so every
+// instance shares the quota, other cache types fall back to memory
+func NewRateLimitStore(conn *e2cache.Connect) RateLimitStore {
+	if conn != nil && conn.Redis() != nil {
+		return NewRedisRateLimitStore(conn.Redis())
+	}
+	return NewMemoryRateLimitStore()
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// slidingWindowResult derive the result from the counts of the current and previous window,
+// elapsed is the time spent in the current window
+func slidingWindowResult(allowed bool, curr, prev float64, limit int, window, elapsed time.Duration) RateLimitResult {
+	weight := float64(window-elapsed) / float64(window)
+	count := prev*weight + curr
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(0, int(math.Floor(float64(limit)-count))),
+		// the previous window stops counting once the current one ended, the current one a window later
+		Reset: window - elapsed,
+	}
+	if curr > 0 {
+		res.Reset += window
+	}
+	if allowed {
+		return res
+	}
+
+	l := float64(limit)
+	if curr < l && prev > 0 {
+		// the weight of the previous window has to drop until count < limit
+		need := float64(window)*(1-(l-curr)/prev) - float64(elapsed)
+		res.RetryAfter = time.Duration(max(need, 0)) + time.Millisecond
+	} else {
+		// wait for the next window, where the current count becomes the previous one
+		res.RetryAfter = window - elapsed + time.Duration(float64(window)*max(1-l/curr, 0)) + time.Millisecond
+	}
+	return res
+}
+
+// tokenBucketResult derive the result from the tokens left, rate is tokens per nanosecond
+func tokenBucketResult(allowed bool, tokens float64, limit int, rate float64) RateLimitResult {
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     limit,
+		Remaining: max(0, int(math.Floor(tokens))),
+		Reset:     time.Duration(math.Ceil((float64(limit) - tokens) / rate)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+	return res
+}
@@ -0,0 +1,191 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MemoryRateLimitStore keep the counters in this process, idle keys are dropped after two of
+// their own windows, so limiters with different windows can share one store
+type MemoryRateLimitStore struct {
+	lock    sync.Mutex
+	windows map[memoryKey]*windowCounter
+	buckets map[memoryKey]*bucketState
+	swept   time.Time
+	now     func() time.Time
+}
+
+// memoryKey keep the counters of limiters with different windows apart
+type memoryKey struct {
+	key    string
+	window time.Duration
+}
+
+type windowCounter struct {
+	start time.Time // start of the current window
+	curr  float64
+	prev  float64
+}
+
+type bucketState struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		windows: make(map[memoryKey]*windowCounter),
+		buckets: make(map[memoryKey]*bucketState),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, alg RateLimitAlgorithm, limit int, window time.Duration) (RateLimitResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := s.now()
+	s.sweep(now, window)
+	mk := memoryKey{key: key, window: window}
+
+	if alg == TokenBucket {
+		rate := float64(limit) / float64(window)
+		b, ok := s.buckets[mk]
+		if !ok {
+			b = &bucketState{tokens: float64(limit), last: now}
+			s.buckets[mk] = b
+		}
+		b.tokens = math.Min(float64(limit), b.tokens+float64(now.Sub(b.last))*rate)
+		b.last = now
+		allowed := b.tokens >= 1
+		if allowed {
+			b.tokens--
+		}
+		return tokenBucketResult(allowed, b.tokens, limit, rate), nil
+	}
+
+	start := now.Truncate(window)
+	w, ok := s.windows[mk]
+	if !ok {
+		w = &windowCounter{start: start}
+		s.windows[mk] = w
+	}
+	switch {
+	case w.start.Equal(start):
+	case w.start.Add(window).Equal(start):
+		w.start, w.prev, w.curr = start, w.curr, 0
+	default:
+		w.start, w.prev, w.curr = start, 0, 0
+	}
+	elapsed := now.Sub(start)
+	allowed := w.prev*float64(window-elapsed)/float64(window)+w.curr < float64(limit)
+	if allowed {
+		w.curr++
+	}
+	return slidingWindowResult(allowed, w.curr, w.prev, limit, window, elapsed), nil
+}
+
+// sweep drop the keys idle for two of their own windows, at most once per window of the caller
+func (s *MemoryRateLimitStore) sweep(now time.Time, window time.Duration) {
+	if now.Sub(s.swept) < window {
+		return
+	}
+	s.swept = now
+	for k, w := range s.windows {
+		if now.Sub(w.start) >= 2*k.window {
+			delete(s.windows, k)
+		}
+	}
+	for k, b := range s.buckets {
+		if now.Sub(b.last) >= 2*k.window {
+			delete(s.buckets, k)
+		}
+	}
+}
+
+// RedisRateLimitStore keep the counters in redis, each decision is one atomic script call,
+// windows follow the clock of the application instances
+type RedisRateLimitStore struct {
+	client redis.Scripter
+}
+
+func NewRedisRateLimitStore(client redis.Scripter) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client}
+}
+
+// KEYS[1] current window, KEYS[2] previous window
+// ARGV[1] limit, ARGV[2] window ms, ARGV[3] ms elapsed in the current window
+var slidingWindowScript = redis.NewScript(`
+local curr = tonumber(redis.call('GET', KEYS[1]) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local elapsed = tonumber(ARGV[3])
+if prev * (window - elapsed) / window + curr < limit then
+	curr = redis.call('INCR', KEYS[1])
+	redis.call('PEXPIRE', KEYS[1], window * 2)
+	return {1, curr, prev}
+end
+return {0, curr, prev}
+`)
+
+// KEYS[1] bucket
+// ARGV[1] capacity, ARGV[2] tokens per ms, ARGV[3] now ms, ARGV[4] ttl ms
+var tokenBucketScript = redis.NewScript(`
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local tokens = tonumber(state[1]) or capacity
+local ts = tonumber(state[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {allowed, tostring(tokens)}
+`)
+
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, alg RateLimitAlgorithm, limit int, window time.Duration) (RateLimitResult, error) {
+	now := time.Now()
+	windowMs := window.Milliseconds()
+
+	if alg == TokenBucket {
+		rate := float64(limit) / float64(window)
+		vs, err := tokenBucketScript.Run(ctx, s.client, []string{key},
+			limit, float64(limit)/float64(windowMs), now.UnixMilli(), 2*windowMs).Slice()
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		if len(vs) != 2 {
+			return RateLimitResult{}, fmt.Errorf("ratelimit: unexpected script result %v", vs)
+		}
+		tokens, err := strconv.ParseFloat(fmt.Sprint(vs[1]), 64)
+		if err != nil {
+			return RateLimitResult{}, err
+		}
+		return tokenBucketResult(vs[0] == int64(1), tokens, limit, rate), nil
+	}
+
+	start := now.Truncate(window)
+	elapsed := now.Sub(start)
+	idx := start.UnixMilli() / windowMs
+	// the hash tag keeps both windows in one slot of a redis cluster
+	keys := []string{fmt.Sprintf("{%s}:%d", key, idx), fmt.Sprintf("{%s}:%d", key, idx-1)}
+	vs, err := slidingWindowScript.Run(ctx, s.client, keys, limit, windowMs, elapsed.Milliseconds()).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(vs) != 3 {
+		return RateLimitResult{}, fmt.Errorf("ratelimit: unexpected script result %v", vs)
+	}
+	return slidingWindowResult(vs[0] == 1, float64(vs[1]), float64(vs[2]), limit, window, elapsed), nil
+}
@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	eng := gin.New()
+	eng.Use(RateLimitMiddleware(RateLimitConfig{Limit: 2, Window: time.Minute, KeyFunc: HeaderKey("X-Api-Key")}))
+	eng.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	call := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		w := httptest.NewRecorder()
+		eng.ServeHTTP(w, req)
+		return w
+	}
+
+	w := call("a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	assert.Equal(t, http.StatusOK, call("a").Code)
+
+	w = call("a")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "too_many_requests", body["message"])
+	assert.NotNil(t, body["detail"].(map[string]any)["retry_after"])
+
+	assert.Equal(t, http.StatusOK, call("b").Code)
+}
+
+func TestMemorySlidingWindow(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.TODO()
+
+	for range 4 {
+		res, _ := s.Allow(ctx, "k", SlidingWindow, 4, time.Minute)
+		assert.True(t, res.Allowed)
+	}
+	res, _ := s.Allow(ctx, "k", SlidingWindow, 4, time.Minute)
+	assert.False(t, res.Allowed)
+	// the next window starts with 4 requests weighted fully, 15s into it 3 still count
+	assert.Equal(t, time.Minute+time.Millisecond, res.RetryAfter)
+
+	now = now.Add(75 * time.Second)
+	res, _ = s.Allow(ctx, "k", SlidingWindow, 4, time.Minute)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	res, _ = s.Allow(ctx, "k", SlidingWindow, 4, time.Minute)
+	assert.False(t, res.Allowed)
+	// 3 + 1 reached the limit, it frees up as soon as the previous window weighs a bit less
+	assert.Equal(t, time.Millisecond, res.RetryAfter)
+
+	now = now.Add(15 * time.Second)
+	res, _ = s.Allow(ctx, "k", SlidingWindow, 4, time.Minute)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+
+	now = now.Add(3 * time.Minute)
+	res, _ = s.Allow(ctx, "k", SlidingWindow, 4, time.Minute)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 3, res.Remaining)
+}
+
+func TestMemoryTokenBucket(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := context.TODO()
+
+	for range 2 {
+		res, _ := s.Allow(ctx, "k", TokenBucket, 2, time.Second)
+		assert.True(t, res.Allowed)
+	}
+	res, _ := s.Allow(ctx, "k", TokenBucket, 2, time.Second)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, time.Second, res.Reset)
+
+	now = now.Add(500 * time.Millisecond)
+	res, _ = s.Allow(ctx, "k", TokenBucket, 2, time.Second)
+	assert.True(t, res.Allowed)
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	dsn := os.Getenv("E2UTIL_TEST_REDIS")
+	if dsn == "" {
+		t.Skip("set E2UTIL_TEST_REDIS=redis://127.0.0.1:6379/0 to run")
+	}
+	opts, err := redis.ParseURL(dsn)
+	assert.NoError(t, err)
+	cli := redis.NewClient(opts)
+	defer cli.Close()
+	s := NewRedisRateLimitStore(cli)
+	key := "ratelimit:test:" + time.Now().Format(time.RFC3339Nano)
+
+	for _, alg := range []RateLimitAlgorithm{SlidingWindow, TokenBucket} {
+		for range 3 {
+			res, err := s.Allow(context.TODO(), key, alg, 3, time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, res.Allowed)
+		}
+		res, err := s.Allow(context.TODO(), key, alg, 3, time.Minute)
+		assert.NoError(t, err)
+		assert.False(t, res.Allowed)
+		assert.Positive(t, res.RetryAfter)
+	}
+}
+
+func TestMemoryStoreSharedWindows(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	ctx := context.TODO()
+
+	// the per second limiter sweeps on every call, the hourly counters must survive it
+	for _, alg := range []RateLimitAlgorithm{SlidingWindow, TokenBucket} {
+		for i := range 3 {
+			res, _ := s.Allow(ctx, "k", alg, 3, time.Hour)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 2-i, res.Remaining)
+			now = now.Add(2 * time.Second)
+			res, _ = s.Allow(ctx, "k", alg, 10, time.Second)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, 9, res.Remaining)
+		}
+		res, _ := s.Allow(ctx, "k", alg, 3, time.Hour)
+		assert.False(t, res.Allowed)
+	}
+
+	// both are dropped once idle for two of their windows
+	now = now.Add(3 * time.Hour)
+	_, _ = s.Allow(ctx, "other", SlidingWindow, 1, time.Second)
+	assert.Len(t, s.windows, 1)
+	assert.Empty(t, s.buckets)
+}
@@ -0,0 +1,48 @@
+package middlewares
+
+import (
+	"github.com/e2u/e2util/e2context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxRequestIDLen bound an incoming X-Request-Id, longer or non printable ids are replaced
+const maxRequestIDLen = 128
+
+// TraceMiddleware accept or generate X-Request-Id and a W3C traceparent, store them as an
+// e2context.Trace in the request context and on c, and echo X-Request-Id in the response.
+// Logs written with logger.WithContext(c) carry them through e2logrus.TraceHook, and
+// e2http.Builder(c.Request.Context()) forwards them
+func TraceMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t := e2context.Trace{RequestID: c.GetHeader(e2context.RequestIDHeader)}
+		if !validRequestID(t.RequestID) {
+			t.RequestID = uuid.NewString()
+		}
+
+		if tp, err := e2context.ParseTraceParent(c.GetHeader(e2context.TraceparentHeader)); err == nil {
+			// the incoming span id is the caller's, this service is a child span
+			t.TraceParent = tp.Child()
+			t.TraceState = c.GetHeader(e2context.TracestateHeader)
+		} else {
+			t.TraceParent = e2context.NewTraceParent()
+		}
+
+		c.Request = c.Request.WithContext(e2context.WithTrace(c.Request.Context(), t))
+		c.Set(e2context.TraceKey, t)
+		c.Header(e2context.RequestIDHeader, t.RequestID)
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
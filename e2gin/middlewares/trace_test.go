@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/e2u/e2util/e2context"
+	"github.com/e2u/e2util/e2http"
+	"github.com/e2u/e2util/e2logrus"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceMiddleware(t *testing.T) {
+	var upstream http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		upstream = req.Header.Clone()
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(&e2logrus.TraceHook{})
+
+	gin.SetMode(gin.TestMode)
+	eng := gin.New()
+	eng.Use(TraceMiddleware())
+	eng.GET("/", func(c *gin.Context) {
+		logger.WithContext(c).Info("handled")
+		_ = e2http.Builder(c.Request.Context()).URL(srv.URL).Do()
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Id", "abc-123")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+
+	assert.Equal(t, "abc-123", w.Header().Get("X-Request-Id"))
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "abc-123", entry["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry["trace_id"])
+	assert.NotEqual(t, "00f067aa0ba902b7", entry["span_id"])
+
+	assert.Equal(t, "abc-123", upstream.Get("X-Request-Id"))
+	assert.True(t, strings.HasPrefix(upstream.Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-"))
+
+	// invalid ids are replaced
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-Id", "bad id")
+	w = httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.NotEqual(t, "bad id", w.Header().Get("X-Request-Id"))
+	assert.NotEmpty(t, w.Header().Get("X-Request-Id"))
+	_, err := e2context.ParseTraceParent(upstream.Get("traceparent"))
+	assert.NoError(t, err)
+}
+
+func TestRequestLoggingTrace(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger.AddHook(&e2logrus.TraceHook{})
+
+	gin.SetMode(gin.TestMode)
+	eng := gin.New()
+	eng.Use(TraceMiddleware(), RequestLoggingMiddleware(logger))
+	eng.POST("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"a":1}`))
+	req.Header.Set(e2context.RequestIDHeader, "req-2")
+	req.Header.Set(e2context.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "request details", entry["msg"])
+	assert.Equal(t, "req-2", entry["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entry["trace_id"])
+	assert.NotEmpty(t, entry["span_id"])
+	assert.NotEqual(t, "00f067aa0ba902b7", entry["span_id"])
+}
@@ -40,6 +40,7 @@ const (
 	NotAcceptable
 	NotImplemented
 	BadGateway
+	TooManyRequests
 )
 
 var (
@@ -58,6 +59,7 @@ var (
 		NotAcceptable:    {HttpCode: http.StatusNotAcceptable, Message: "not_acceptable"},
 		NotImplemented:   {HttpCode: http.StatusNotImplemented, Message: "not_implemented"},
 		BadGateway:       {HttpCode: http.StatusBadGateway, Message: "bad_gateway"},
+		TooManyRequests:  {HttpCode: http.StatusTooManyRequests, Message: "too_many_requests"},
 	}
 )
 
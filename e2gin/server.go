@@ -0,0 +1,276 @@
+package e2gin
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var draining atomic.Bool
+
+// Ready report whether the health endpoint answers OK, it turns false once a Server starts draining
+func Ready() bool {
+	return !draining.Load()
+}
+
+// SetReady flip the health endpoint between OK and 503, e.g. while warming caches on startup
+func SetReady(ready bool) {
+	draining.Store(!ready)
+}
+
+type ServerOption struct {
+	Address string
+	Port    int
+
+	// CertFile and KeyFile serve TLS, the pair is loaded again when either file changes on disk,
+	// so renewed or remounted certificates are picked up without a restart
+	CertFile           string
+	KeyFile            string
+	CertReloadInterval time.Duration // how often the files are checked, default 10s
+	TLSConfig          *tls.Config   // base TLS config, serve TLS with its own certificates when CertFile is empty
+
+	H2C          bool // also serve HTTP/2 over plain TCP, TLS always negotiates h2 unless DisableHTTP2
+	DisableHTTP2 bool
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration // default 10s
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// DrainDelay keep serving after the health endpoint turned not-ready, so load balancers
+	// have time to take the instance out before it stops accepting connections
+	DrainDelay      time.Duration
+	ShutdownTimeout time.Duration // deadline for in-flight requests, default 30s
+	Signals         []os.Signal   // default SIGINT and SIGTERM
+}
+
+// Server run an engine with TLS, HTTP/2 and graceful draining
+//
+//	srv, err := e2gin.NewServer(eng, e2gin.ServerOption{Port: 8443, CertFile: "tls.crt", KeyFile: "tls.key"})
+//	if err != nil {
+//		logrus.Fatal(err)
+//	}
+//	err = srv.Run(func() { db.Close() })
+type Server struct {
+	opt   ServerOption
+	srv   *http.Server
+	certs *certReloader
+}
+
+func NewServer(handler http.Handler, opt ServerOption) (*Server, error) {
+	if opt.ReadHeaderTimeout == 0 {
+		opt.ReadHeaderTimeout = 10 * time.Second
+	}
+	if opt.ShutdownTimeout <= 0 {
+		opt.ShutdownTimeout = 30 * time.Second
+	}
+	if len(opt.Signals) == 0 {
+		opt.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+
+	s := &Server{opt: opt}
+	s.srv = &http.Server{
+		Addr:              fmt.Sprintf("%s:%d", opt.Address, opt.Port),
+		Handler:           handler,
+		ReadTimeout:       opt.ReadTimeout,
+		ReadHeaderTimeout: opt.ReadHeaderTimeout,
+		WriteTimeout:      opt.WriteTimeout,
+		IdleTimeout:       opt.IdleTimeout,
+		MaxHeaderBytes:    opt.MaxHeaderBytes,
+		Protocols:         new(http.Protocols),
+	}
+	s.srv.Protocols.SetHTTP1(true)
+	s.srv.Protocols.SetHTTP2(!opt.DisableHTTP2)
+	s.srv.Protocols.SetUnencryptedHTTP2(opt.H2C && !opt.DisableHTTP2)
+
+	if opt.TLSConfig != nil {
+		s.srv.TLSConfig = opt.TLSConfig.Clone()
+	}
+	if opt.CertFile != "" || opt.KeyFile != "" {
+		certs, err := newCertReloader(opt.CertFile, opt.KeyFile, opt.CertReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		s.certs = certs
+		if s.srv.TLSConfig == nil {
+			s.srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		s.srv.TLSConfig.GetCertificate = certs.GetCertificate
+	}
+	return s, nil
+}
+
+// HttpServer return the underlying server, e.g. to set ErrorLog or ConnState
+func (s *Server) HttpServer() *http.Server {
+	return s.srv
+}
+
+func (s *Server) tls() bool {
+	return s.srv.TLSConfig != nil
+}
+
+// Serve accept connections on ln until Drain or Close, it returns http.ErrServerClosed after a drain
+func (s *Server) Serve(ln net.Listener) error {
+	if s.tls() {
+		logrus.Infof("https server listening on %s", ln.Addr())
+		return s.srv.ServeTLS(ln, "", "")
+	}
+	logrus.Infof("http server listening on %s", ln.Addr())
+	return s.srv.Serve(ln)
+}
+
+func (s *Server) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Drain turn the health endpoint not-ready, wait DrainDelay, then stop accepting connections and
+// wait for in-flight requests until ctx is done, the remaining connections are closed on timeout
+func (s *Server) Drain(ctx context.Context) error {
+	SetReady(false)
+	if s.opt.DrainDelay > 0 {
+		logrus.Infof("health is not-ready, draining for %v", s.opt.DrainDelay)
+		select {
+		case <-time.After(s.opt.DrainDelay):
+		case <-ctx.Done():
+		}
+	}
+	if err := s.srv.Shutdown(ctx); err != nil {
+		_ = s.srv.Close()
+		return fmt.Errorf("e2gin: drain: %w", err)
+	}
+	return nil
+}
+
+// Run serve until one of the signals arrives, then drain within ShutdownTimeout and call stop,
+// a second signal skips the rest of the drain
+func (s *Server) Run(stop func()) error {
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, s.opt.Signals...)
+	defer signal.Stop(sigChan)
+
+	errChan := make(chan error, 1)
+	go func() {
+		logrus.Infof("Server started. Press Ctrl+C to stop.")
+		errChan <- s.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case sig := <-sigChan:
+		logrus.Infof("Received %v. Shutting down...", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.opt.ShutdownTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case sig := <-sigChan:
+			logrus.Warnf("Received %v again, closing connections", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := s.Drain(ctx)
+	if sErr := <-errChan; sErr != nil && !errors.Is(sErr, http.ErrServerClosed) && err == nil {
+		err = sErr
+	}
+	if stop != nil {
+		stop()
+	}
+	return err
+}
+
+// certReloader serve the key pair of certFile and keyFile, reloading it when the modification
+// time of either file changes, a broken pair is logged and the previous one kept
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	checked  time.Time
+}
+
+func newCertReloader(certFile, keyFile string, interval time.Duration) (*certReloader, error) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	c := &certReloader{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := c.reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return latest, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload must be called with the lock held or before the reloader is shared
+func (c *certReloader) reload() error {
+	c.checked = time.Now()
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return fmt.Errorf("e2gin: load certificate: %w", err)
+	}
+	if c.cert != nil && modTime.Equal(c.modTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("e2gin: load certificate: %w", err)
+	}
+	if c.cert != nil {
+		logrus.Infof("reloaded certificate %s", c.certFile)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.lock.RLock()
+	cert, fresh := c.cert, time.Since(c.checked) < c.interval
+	c.lock.RUnlock()
+	if fresh {
+		return cert, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if time.Since(c.checked) >= c.interval {
+		if err := c.reload(); err != nil {
+			logrus.Errorf("keep the previous certificate, error=%v", err)
+		}
+	}
+	return c.cert, nil
+}
@@ -0,0 +1,162 @@
+package e2gin
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestCert(t *testing.T, certFile, keyFile string, serial int64) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	// make sure the reloader sees a new modification time even on coarse filesystems
+	mt := time.Now().Add(time.Duration(serial) * time.Second)
+	assert.NoError(t, os.Chtimes(certFile, mt, mt))
+	assert.NoError(t, os.Chtimes(keyFile, mt, mt))
+}
+
+func serveTest(t *testing.T, s *Server) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() { _ = s.Serve(ln) }()
+	t.Cleanup(func() { _ = s.HttpServer().Close() })
+	return ln.Addr().String()
+}
+
+func TestServerCertReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeTestCert(t, certFile, keyFile, 1)
+
+	s, err := NewServer(http.NotFoundHandler(), ServerOption{CertFile: certFile, KeyFile: keyFile, CertReloadInterval: time.Millisecond})
+	assert.NoError(t, err)
+	addr := serveTest(t, s)
+
+	handshake := func() (int64, string) {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}}) // #nosec G402
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer conn.Close()
+		st := conn.ConnectionState()
+		return st.PeerCertificates[0].SerialNumber.Int64(), st.NegotiatedProtocol
+	}
+
+	serial, proto := handshake()
+	assert.EqualValues(t, 1, serial)
+	assert.Equal(t, "h2", proto)
+
+	writeTestCert(t, certFile, keyFile, 2)
+	time.Sleep(5 * time.Millisecond)
+	serial, _ = handshake()
+	assert.EqualValues(t, 2, serial)
+
+	// a broken pair keeps the previous certificate
+	assert.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	time.Sleep(5 * time.Millisecond)
+	serial, _ = handshake()
+	assert.EqualValues(t, 2, serial)
+
+	_, err = NewServer(http.NotFoundHandler(), ServerOption{CertFile: certFile, KeyFile: keyFile})
+	assert.Error(t, err)
+}
+
+func TestServerH2C(t *testing.T) {
+	s, err := NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), ServerOption{H2C: true})
+	assert.NoError(t, err)
+	addr := serveTest(t, s)
+
+	tr := &http.Transport{Protocols: new(http.Protocols)}
+	tr.Protocols.SetUnencryptedHTTP2(true)
+	resp, err := (&http.Client{Transport: tr}).Get("http://" + addr + "/")
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, 2, resp.ProtoMajor)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestServerDrain(t *testing.T) {
+	defer SetReady(true)
+
+	eng := DefaultEngine(&Option{DisabledPprof: true, DisableGzip: true})
+	entered, release := make(chan struct{}), make(chan struct{})
+	eng.GET("/slow", func(c *gin.Context) {
+		close(entered)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+	s, err := NewServer(eng, ServerOption{DrainDelay: 100 * time.Millisecond})
+	assert.NoError(t, err)
+	addr := serveTest(t, s)
+	healthURL := "http://" + addr + "/__app/_health"
+
+	resp, err := http.Get(healthURL)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	slow := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		slow <- resp.StatusCode
+	}()
+	<-entered
+
+	drained := make(chan error, 1)
+	go func() { drained <- s.Drain(context.Background()) }()
+
+	// still accepting during DrainDelay, but not-ready
+	time.Sleep(20 * time.Millisecond)
+	resp, err = http.Get(healthURL)
+	assert.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// the in-flight request holds the drain
+	select {
+	case <-drained:
+		t.Fatal("drained before the in-flight request finished")
+	case <-time.After(150 * time.Millisecond):
+	}
+	close(release)
+	assert.Equal(t, http.StatusOK, <-slow)
+	assert.NoError(t, <-drained)
+
+	_, err = http.Get(healthURL)
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package e2health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker probe a dependency, e2db.Connect, e2cache.Connect and e2bdb.BDB implement it
+type Checker interface {
+	Ping(ctx context.Context) error
+}
+
+// CheckFunc adapt a function to Checker
+type CheckFunc func(ctx context.Context) error
+
+func (f CheckFunc) Ping(ctx context.Context) error {
+	return f(ctx)
+}
+
+type CheckOptions struct {
+	Timeout time.Duration // default 3s
+	// Liveness also run the check for /livez, keep it to failures a restart would fix,
+	// every check is part of /readyz
+	Liveness bool
+	Optional bool // reported, but does not fail the status
+}
+
+func DefaultCheckOptions() CheckOptions {
+	return CheckOptions{Timeout: 3 * time.Second}
+}
+
+func (opt CheckOptions) WithTimeout(timeout time.Duration) CheckOptions {
+	opt.Timeout = timeout
+	return opt
+}
+
+func (opt CheckOptions) WithLiveness(val bool) CheckOptions {
+	opt.Liveness = val
+	return opt
+}
+
+func (opt CheckOptions) WithOptional(val bool) CheckOptions {
+	opt.Optional = val
+	return opt
+}
+
+// Result of one check, kept for the cache TTL of the registry
+type Result struct {
+	Status     string    `json:"status"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	CheckedAt  time.Time `json:"checked_at"`
+	Optional   bool      `json:"optional,omitempty"`
+}
+
+// Report of a set of checks, Status is fail when any non optional check failed
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type check struct {
+	checker Checker
+	opts    CheckOptions
+	lock    sync.Mutex
+	last    *Result
+}
+
+// Registry hold the checks of a process, results are cached for a TTL and concurrent callers
+// share one probe, so a flood of health requests can't flood the dependencies
+type Registry struct {
+	ttl    time.Duration
+	lock   sync.RWMutex
+	checks map[string]*check
+	group  singleflight.Group
+}
+
+// NewRegistry cache results for ttl, zero or negative probes on every call
+func NewRegistry(ttl time.Duration) *Registry {
+	return &Registry{ttl: ttl, checks: make(map[string]*check)}
+}
+
+// Default registry served by the e2gin health endpoints
+var Default = NewRegistry(5 * time.Second)
+
+// Register add c to the Default registry
+//
+//	e2health.Register("db", dbConn)
+//	e2health.Register("cache", cacheConn, e2health.DefaultCheckOptions().WithOptional(true))
+func Register(name string, c Checker, opts ...CheckOptions) {
+	Default.Register(name, c, opts...)
+}
+
+// Register add or replace the check called name
+func (r *Registry) Register(name string, c Checker, opts ...CheckOptions) {
+	opt := DefaultCheckOptions()
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = DefaultCheckOptions().Timeout
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.checks[name] = &check{checker: c, opts: opt}
+}
+
+func (r *Registry) Unregister(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.checks, name)
+}
+
+// Live run the liveness checks
+func (r *Registry) Live(ctx context.Context) Report {
+	return r.run(ctx, true)
+}
+
+// Ready run every check
+func (r *Registry) Ready(ctx context.Context) Report {
+	return r.run(ctx, false)
+}
+
+func (r *Registry) run(ctx context.Context, liveOnly bool) Report {
+	r.lock.RLock()
+	checks := make(map[string]*check, len(r.checks))
+	for name, c := range r.checks {
+		if !liveOnly || c.opts.Liveness {
+			checks[name] = c
+		}
+	}
+	r.lock.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := r.result(ctx, name, c)
+			lock.Lock()
+			defer lock.Unlock()
+			report.Checks[name] = res
+			if res.Status != StatusOK && !res.Optional {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (r *Registry) result(ctx context.Context, name string, c *check) Result {
+	c.lock.Lock()
+	last := c.last
+	c.lock.Unlock()
+	if last != nil && time.Since(last.CheckedAt) < r.ttl {
+		return *last
+	}
+
+	ch := r.group.DoChan(name, func() (any, error) {
+		// the probe outlives a canceled caller, others may be waiting for it
+		pctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.opts.Timeout)
+		defer cancel()
+		res := probe(pctx, c.checker)
+		res.Optional = c.opts.Optional
+		c.lock.Lock()
+		c.last = &res
+		c.lock.Unlock()
+		return res, nil
+	})
+	select {
+	case <-ctx.Done():
+		return Result{Status: StatusFail, Error: ctx.Err().Error(), CheckedAt: time.Now(), Optional: c.opts.Optional}
+	case v := <-ch:
+		return v.Val.(Result)
+	}
+}
+
+func probe(ctx context.Context, c Checker) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- c.Ping(ctx)
+	}()
+
+	res := Result{Status: StatusOK, CheckedAt: start}
+	select {
+	case err := <-done:
+		if err != nil {
+			res.Status, res.Error = StatusFail, err.Error()
+		}
+	case <-ctx.Done():
+		// a checker ignoring ctx must not hold the report
+		res.Status, res.Error = StatusFail, ctx.Err().Error()
+	}
+	res.DurationMs = time.Since(start).Milliseconds()
+	return res
+}
@@ -0,0 +1,70 @@
+package e2health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(time.Minute)
+	var dbCalls atomic.Int32
+	reg.Register("db", CheckFunc(func(ctx context.Context) error {
+		dbCalls.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}))
+	reg.Register("cache", CheckFunc(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}), DefaultCheckOptions().WithOptional(true))
+	reg.Register("loop", CheckFunc(func(ctx context.Context) error {
+		return nil
+	}), DefaultCheckOptions().WithLiveness(true))
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report := reg.Ready(context.TODO())
+			assert.True(t, report.OK())
+			assert.Len(t, report.Checks, 3)
+			assert.Equal(t, StatusFail, report.Checks["cache"].Status)
+			assert.Equal(t, "connection refused", report.Checks["cache"].Error)
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, dbCalls.Load())
+
+	live := reg.Live(context.TODO())
+	assert.True(t, live.OK())
+	assert.Len(t, live.Checks, 1)
+	assert.Contains(t, live.Checks, "loop")
+}
+
+func TestRegistryFailures(t *testing.T) {
+	reg := NewRegistry(0)
+	reg.Register("slow", CheckFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}), DefaultCheckOptions().WithTimeout(20*time.Millisecond))
+	reg.Register("panic", CheckFunc(func(ctx context.Context) error {
+		panic("boom")
+	}))
+
+	start := time.Now()
+	report := reg.Ready(context.TODO())
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.False(t, report.OK())
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+	assert.Equal(t, "panic: boom", report.Checks["panic"].Error)
+
+	reg.Unregister("slow")
+	reg.Unregister("panic")
+	assert.True(t, reg.Ready(context.TODO()).OK())
+}
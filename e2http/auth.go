@@ -0,0 +1,256 @@
+package e2http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// AuthProvider put credentials on outgoing requests, see Context.Auth
+type AuthProvider interface {
+	// Authorize set the credentials of req, e.g. its Authorization header
+	Authorize(req *http.Request) error
+	// Invalidate is called when the server answered req with 401, it returns true when
+	// the credentials changed and the request is worth sending once more
+	Invalidate(req *http.Request) bool
+}
+
+// AuthChallenger is implemented by providers which take their credentials from the 401 answer,
+// e.g. Digest, AuthMiddleware calls Challenge instead of Invalidate for them
+type AuthChallenger interface {
+	Challenge(req *http.Request, resp *http.Response) bool
+}
+
+// Auth authorize this request with p and send it once more with fresh credentials on 401,
+// attach p to a Client with Client.Use(e2http.AuthMiddleware(p))
+func (r *Context) Auth(p AuthProvider) *Context {
+	return r.Use(AuthMiddleware(p))
+}
+
+func AuthMiddleware(p AuthProvider) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			first := req.Clone(req.Context())
+			if err := p.Authorize(first); err != nil {
+				return nil, err
+			}
+			resp, err := next(first)
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				return resp, err
+			}
+			if c, ok := p.(AuthChallenger); ok {
+				if !c.Challenge(first, resp) {
+					return resp, nil
+				}
+			} else if !p.Invalidate(first) {
+				return resp, nil
+			}
+
+			// Authorize may have buffered the body of first, its GetBody is the one to use
+			second := req.Clone(req.Context())
+			if req.Body != nil && req.Body != http.NoBody {
+				if first.GetBody == nil {
+					return resp, nil
+				}
+				body, err := first.GetBody()
+				if err != nil {
+					return resp, nil
+				}
+				second.Body = body
+			}
+			drainBody(resp.Body)
+			if err := p.Authorize(second); err != nil {
+				return nil, err
+			}
+			return next(second)
+		}
+	}
+}
+
+// ErrNoRefreshToken is returned by a refresh-token provider created without a refresh token
+var ErrNoRefreshToken = errors.New("e2http: oauth2 refresh token is empty")
+
+// Token is an OAuth2 access token
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time // zero means the token does not expire
+}
+
+// OAuth2Error is returned when the token endpoint rejects the request, https://www.rfc-editor.org/rfc/rfc6749#section-5.2
+type OAuth2Error struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *OAuth2Error) Error() string {
+	msg := fmt.Sprintf("e2http: oauth2 token request failed with status %d", e.StatusCode)
+	if e.Code != "" {
+		msg += ": " + e.Code
+	}
+	if e.Description != "" {
+		msg += " " + e.Description
+	}
+	return msg
+}
+
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	AuthInBody   bool          // send the client credentials as form fields instead of basic auth
+	ExpiryDelta  time.Duration // refresh this long before the token expires, default 30s, the token is kept if that fails
+	Client       *Client       // used for the token requests, default the shared client, it must not use this provider
+}
+
+// OAuth2Provider fetch, cache and refresh OAuth2 access tokens, concurrent requests share one fetch
+type OAuth2Provider struct {
+	cfg          OAuth2Config
+	grantType    string
+	lock         sync.Mutex
+	token        *Token
+	refreshToken string
+	group        singleflight.Group
+}
+
+// NewClientCredentials create a provider using the client_credentials grant
+func NewClientCredentials(cfg OAuth2Config) *OAuth2Provider {
+	return newOAuth2Provider(cfg, "client_credentials", "")
+}
+
+// NewRefreshToken create a provider using the refresh_token grant, a rotated refresh token
+// returned by the server replaces refreshToken
+func NewRefreshToken(cfg OAuth2Config, refreshToken string) *OAuth2Provider {
+	return newOAuth2Provider(cfg, "refresh_token", refreshToken)
+}
+
+func newOAuth2Provider(cfg OAuth2Config, grantType, refreshToken string) *OAuth2Provider {
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = 30 * time.Second
+	}
+	if cfg.Client == nil {
+		cfg.Client = defaultClient
+	}
+	return &OAuth2Provider{cfg: cfg, grantType: grantType, refreshToken: refreshToken}
+}
+
+func (p *OAuth2Provider) Middleware() Middleware {
+	return AuthMiddleware(p)
+}
+
+func (p *OAuth2Provider) Authorize(req *http.Request) error {
+	t, err := p.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	typ := t.TokenType
+	if typ == "" || strings.EqualFold(typ, "bearer") {
+		typ = "Bearer"
+	}
+	req.Header.Set("Authorization", typ+" "+t.AccessToken)
+	return nil
+}
+
+// Invalidate drop the cached token when req carried it, a token refreshed meanwhile is kept
+func (p *OAuth2Provider) Invalidate(req *http.Request) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.token != nil && strings.HasSuffix(req.Header.Get("Authorization"), " "+p.token.AccessToken) {
+		p.token = nil
+	}
+	return true
+}
+
+// Token return the cached token, fetching a new one when it is missing or about to expire
+func (p *OAuth2Provider) Token(ctx context.Context) (*Token, error) {
+	p.lock.Lock()
+	t := p.token
+	p.lock.Unlock()
+	if t != nil && (t.Expiry.IsZero() || time.Now().Add(p.cfg.ExpiryDelta).Before(t.Expiry)) {
+		return t, nil
+	}
+
+	// the fetch outlives a caller giving up, the others waiting on it still get the token
+	ch := p.group.DoChan("token", func() (any, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		return p.fetch(fetchCtx)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			// refreshing ahead is best effort, the cached token is good until it really expires
+			if t != nil && time.Now().Before(t.Expiry) {
+				return t, nil
+			}
+			return nil, res.Err
+		}
+		return res.Val.(*Token), nil
+	}
+}
+
+func (p *OAuth2Provider) fetch(ctx context.Context) (*Token, error) {
+	form := url.Values{"grant_type": {p.grantType}}
+	if len(p.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	}
+	if p.grantType == "refresh_token" {
+		p.lock.Lock()
+		refreshToken := p.refreshToken
+		p.lock.Unlock()
+		if refreshToken == "" {
+			return nil, ErrNoRefreshToken
+		}
+		form.Set("refresh_token", refreshToken)
+	}
+
+	r := p.cfg.Client.Request(ctx).URL(p.cfg.TokenURL).SetHeader("Accept", "application/json")
+	if p.cfg.AuthInBody {
+		form.Set("client_id", p.cfg.ClientID)
+		form.Set("client_secret", p.cfg.ClientSecret)
+	} else {
+		r.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+	r.PostForm(strings.NewReader(form.Encode())).Do()
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		RefreshToken     string `json:"refresh_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	_ = json.Unmarshal(r.Body(), &body)
+	if r.StatusCode() != http.StatusOK || body.AccessToken == "" {
+		return nil, &OAuth2Error{StatusCode: r.StatusCode(), Code: body.Error, Description: body.ErrorDescription}
+	}
+
+	t := &Token{AccessToken: body.AccessToken, TokenType: body.TokenType, RefreshToken: body.RefreshToken}
+	if body.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	p.lock.Lock()
+	p.token = t
+	if t.RefreshToken != "" {
+		p.refreshToken = t.RefreshToken
+	}
+	p.lock.Unlock()
+	return t, nil
+}
@@ -0,0 +1,127 @@
+package e2http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuth2ClientCredentials(t *testing.T) {
+	var issued atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, secret, _ := req.BasicAuth()
+		if id != "app" || secret != "s3cret" || req.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(w, `{"error":"invalid_client"}`)
+			return
+		}
+		n := issued.Add(1)
+		_, _ = fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":3600,"scope":%q}`, n, req.FormValue("scope"))
+	}))
+	defer tokenSrv.Close()
+
+	// the api only accepts the latest token, as if the first one was revoked
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		want := fmt.Sprintf("Bearer t%d", issued.Load())
+		if req.Header.Get("Authorization") != want || issued.Load() < 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		b, _ := io.ReadAll(req.Body)
+		_, _ = io.WriteString(w, "ok "+string(b))
+	}))
+	defer api.Close()
+
+	p := NewClientCredentials(OAuth2Config{TokenURL: tokenSrv.URL, ClientID: "app", ClientSecret: "s3cret", Scopes: []string{"a", "b"}})
+	r := Builder(context.TODO()).URL(api.URL).Auth(p).PostJSON(strings.NewReader(`{}`)).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, http.StatusOK, r.StatusCode())
+	assert.Equal(t, "ok {}", r.BodyString())
+	assert.EqualValues(t, 2, issued.Load())
+
+	// concurrent requests reuse the cached token
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, http.StatusOK, Builder(context.TODO()).URL(api.URL).Auth(p).Do().StatusCode())
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 2, issued.Load())
+
+	bad := NewClientCredentials(OAuth2Config{TokenURL: tokenSrv.URL, ClientID: "app", ClientSecret: "wrong"})
+	r = Builder(context.TODO()).URL(api.URL).Auth(bad).Do()
+	var oe *OAuth2Error
+	assert.True(t, errors.As(r.Err(), &oe))
+	assert.Equal(t, "invalid_client", oe.Code)
+}
+
+func TestOAuth2RefreshToken(t *testing.T) {
+	var calls atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		if req.FormValue("grant_type") != "refresh_token" || req.FormValue("client_id") != "app" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// expires_in 1 is inside ExpiryDelta, so every call refreshes and rotates the refresh token
+		_, _ = fmt.Fprintf(w, `{"access_token":"a-%s","refresh_token":"%s-next","expires_in":1}`,
+			req.FormValue("refresh_token"), req.FormValue("refresh_token"))
+	}))
+	defer tokenSrv.Close()
+
+	p := NewRefreshToken(OAuth2Config{TokenURL: tokenSrv.URL, ClientID: "app", AuthInBody: true}, "r0")
+	tok, err := p.Token(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "a-r0", tok.AccessToken)
+	tok, err = p.Token(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "a-r0-next", tok.AccessToken)
+	assert.EqualValues(t, 2, calls.Load())
+
+	_, err = NewRefreshToken(OAuth2Config{TokenURL: tokenSrv.URL}, "").Token(context.TODO())
+	assert.ErrorIs(t, err, ErrNoRefreshToken)
+}
+
+func TestOAuth2EarlyRefreshFailure(t *testing.T) {
+	var calls atomic.Int32
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, `{"access_token":"t1","token_type":"bearer","expires_in":10}`)
+	}))
+	defer tokenSrv.Close()
+
+	// 10s is within the default 30s ExpiryDelta, every call tries to refresh
+	p := NewClientCredentials(OAuth2Config{TokenURL: tokenSrv.URL, ClientID: "app", ClientSecret: "s3cret"})
+	tok, err := p.Token(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", tok.AccessToken)
+
+	tok, err = p.Token(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, "t1", tok.AccessToken)
+	assert.EqualValues(t, 2, calls.Load())
+
+	// once it really expired the failure surfaces
+	p.lock.Lock()
+	p.token.Expiry = time.Now().Add(-time.Second)
+	p.lock.Unlock()
+	_, err = p.Token(context.TODO())
+	assert.Error(t, err)
+	assert.EqualValues(t, 3, calls.Load())
+}
@@ -0,0 +1,117 @@
+package e2http
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// BodyTooLargeError is returned when the response body exceeds the MaxBodySize limit
+type BodyTooLargeError struct {
+	Limit int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("e2http: response body exceeds the limit of %d bytes", e.Limit)
+}
+
+// Streaming make Do hand the response body to Write/Stream/ToJSON directly instead of buffering it,
+// Body() and BodyString() stay empty in this mode
+func (r *Context) Streaming() *Context {
+	r.streaming = true
+	return r
+}
+
+// Stream consume the response body with fn, implies Streaming
+func (r *Context) Stream(fn func(rd io.Reader) error) *Context {
+	r.streaming = true
+	r.streamFn = fn
+	return r
+}
+
+// MaxBodySize abort reading once the response body crosses n bytes, 0 means no limit
+func (r *Context) MaxBodySize(n int64) *Context {
+	r.maxBodySize = n
+	return r
+}
+
+func (r *Context) limitBody(resp *http.Response) io.Reader {
+	if r.maxBodySize <= 0 {
+		return resp.Body
+	}
+	if resp.ContentLength > r.maxBodySize {
+		return &maxBytesReader{err: &BodyTooLargeError{Limit: r.maxBodySize}}
+	}
+	return &maxBytesReader{rd: resp.Body, remaining: r.maxBodySize, limit: r.maxBodySize}
+}
+
+// consumeStream feed the body to the stream consumers, outWriter receives a copy of everything read
+func (r *Context) consumeStream(resp *http.Response, body io.Reader) error {
+	if r.download != nil && r.download.path != "" {
+		return r.download.write(r.responseRequest(resp), resp, body)
+	}
+
+	rd := body
+	if r.outWriter != nil {
+		if r.streamFn == nil && r.toJsonPointer == nil && r.decodePointer == nil {
+			_, err := io.Copy(r.outWriter, rd)
+			return err
+		}
+		rd = io.TeeReader(rd, r.outWriter)
+	}
+
+	switch {
+	case r.streamFn != nil:
+		if err := r.streamFn(rd); err != nil {
+			return err
+		}
+	case r.toJsonPointer != nil:
+		if err := json.NewDecoder(rd).Decode(r.toJsonPointer); err != nil {
+			return err
+		}
+	case r.decodePointer != nil:
+		b, err := io.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+		if err := r.decode(resp.Header.Get("Content-Type"), b, r.decodePointer); err != nil {
+			return err
+		}
+	}
+
+	if r.outWriter != nil {
+		_, err := io.Copy(io.Discard, rd)
+		return err
+	}
+	return nil
+}
+
+type maxBytesReader struct {
+	rd        io.Reader
+	remaining int64
+	limit     int64
+	err       error
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// read one byte more than allowed to find out whether the body is over the limit
+	if int64(len(p))-1 > m.remaining {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.rd.Read(p)
+	if int64(n) <= m.remaining {
+		m.remaining -= int64(n)
+		return n, err
+	}
+	n = int(m.remaining)
+	m.remaining = 0
+	m.err = &BodyTooLargeError{Limit: m.limit}
+	return n, m.err
+}
@@ -0,0 +1,81 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreaming(t *testing.T) {
+	payload := strings.Repeat("0123456789", 1000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.(http.Flusher).Flush() // force chunked encoding, no Content-Length
+		_, _ = io.WriteString(w, payload)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	var n int64
+	r := Builder(context.TODO()).
+		URL(srv.URL).
+		Write(&buf).
+		Stream(func(rd io.Reader) error {
+			var err error
+			n, err = io.Copy(io.Discard, rd)
+			return err
+		}).
+		Do()
+	assert.Empty(t, r.Errors())
+	assert.Equal(t, int64(len(payload)), n)
+	assert.Equal(t, payload, buf.String())
+	assert.Empty(t, r.Body())
+
+	r = Builder(context.TODO()).URL(srv.URL).Streaming().Write(io.Discard).MaxBodySize(100).Do()
+	var tooLarge *BodyTooLargeError
+	assert.Len(t, r.Errors(), 1)
+	assert.True(t, errors.As(r.Errors()[0], &tooLarge))
+	assert.Equal(t, int64(100), tooLarge.Limit)
+
+	r = Builder(context.TODO()).URL(srv.URL).MaxBodySize(int64(len(payload))).Do()
+	assert.Empty(t, r.Errors())
+	assert.Equal(t, payload, r.BodyString())
+}
+
+func TestErrorOnNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Reason", "maintenance")
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+	defer srv.Close()
+
+	var out bytes.Buffer
+	r := Builder(context.TODO()).URL(srv.URL).ErrorOnNon2xx().Write(&out).Do()
+
+	var he *HTTPError
+	assert.True(t, errors.As(r.Err(), &he))
+	assert.Equal(t, http.StatusInternalServerError, he.StatusCode)
+	assert.Equal(t, http.MethodGet, he.Method)
+	assert.Equal(t, "maintenance", he.Header.Get("X-Reason"))
+	assert.Len(t, he.Body, maxErrorBodySnippet)
+	assert.Len(t, r.Body(), 1000)
+	assert.Zero(t, out.Len())
+
+	r = Builder(context.TODO()).URL(srv.URL).ExpectStatus(http.StatusInternalServerError).Do()
+	assert.NoError(t, r.Err())
+
+	// a body over the limit is reported next to the status, not silently cut
+	r = Builder(context.TODO()).URL(srv.URL).ErrorOnNon2xx().MaxBodySize(100).Do()
+	var tooLarge *BodyTooLargeError
+	assert.True(t, errors.As(r.Err(), &he))
+	assert.True(t, errors.As(r.Err(), &tooLarge))
+	assert.Empty(t, r.Body())
+}
@@ -0,0 +1,275 @@
+package e2http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is matched by errors.Is for every request rejected by an open circuit
+var ErrCircuitOpen = errors.New("e2http: circuit open")
+
+// CircuitOpenError is returned without sending the request while the circuit of Host is open
+type CircuitOpenError struct {
+	Host  string
+	State CircuitState
+}
+
+func (e *CircuitOpenError) Error() string {
+	return "e2http: circuit " + e.State.String() + " for " + e.Host
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type CircuitState int
+
+const (
+	CircuitClosed   CircuitState = iota // requests flow, failures are counted
+	CircuitOpen                         // requests fail fast until OpenDuration has passed
+	CircuitHalfOpen                     // a few probe requests decide whether to close or open again
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type BreakerOptions struct {
+	FailureRatio   float64       // open once failures/requests reaches it within Window
+	MinRequests    int           // requests needed within Window before the ratio is considered
+	Window         time.Duration // counting period of the closed state
+	OpenDuration   time.Duration // how long to fail fast before probing
+	HalfOpenProbes int           // probes allowed in half-open, all of them must succeed to close
+	// IsFailure decide whether an exchange counts as a failure, default transport errors and 5xx
+	IsFailure     func(resp *http.Response, err error) bool
+	OnStateChange func(host string, from, to CircuitState)
+}
+
+func DefaultBreakerOptions() BreakerOptions {
+	return BreakerOptions{
+		FailureRatio:   0.5,
+		MinRequests:    20,
+		Window:         10 * time.Second,
+		OpenDuration:   30 * time.Second,
+		HalfOpenProbes: 1,
+	}
+}
+
+// CircuitBreaker keep one closed/open/half-open circuit per host, closed circuits whose Window
+// ended with nothing in flight are forgotten, a new one behaves the same
+type CircuitBreaker struct {
+	opts       BreakerOptions
+	lock       sync.Mutex
+	hosts      map[string]*circuit
+	swept      time.Time
+	sweepEvery time.Duration
+}
+
+type circuit struct {
+	state       CircuitState
+	generation  uint64 // bumped on every transition, results of an older generation are dropped
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int // half-open probes in flight
+	successes   int // half-open probes succeeded
+	active      int // requests in flight, a circuit with some is never evicted
+}
+
+type stateChange struct {
+	host     string
+	from, to CircuitState
+}
+
+func NewCircuitBreaker(opts BreakerOptions) *CircuitBreaker {
+	def := DefaultBreakerOptions()
+	if opts.FailureRatio <= 0 {
+		opts.FailureRatio = def.FailureRatio
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = def.MinRequests
+	}
+	if opts.Window <= 0 {
+		opts.Window = def.Window
+	}
+	if opts.OpenDuration <= 0 {
+		opts.OpenDuration = def.OpenDuration
+	}
+	if opts.HalfOpenProbes <= 0 {
+		opts.HalfOpenProbes = def.HalfOpenProbes
+	}
+	if opts.IsFailure == nil {
+		opts.IsFailure = isFailure
+	}
+	return &CircuitBreaker{opts: opts, hosts: make(map[string]*circuit), swept: time.Now(), sweepEvery: hostSweepInterval}
+}
+
+func isFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= http.StatusInternalServerError
+}
+
+// CircuitBreaker guard this request with b, attach b to a Client with Client.Use(b.Middleware())
+func (r *Context) CircuitBreaker(b *CircuitBreaker) *Context {
+	return r.Use(b.Middleware())
+}
+
+// State return the current state of host, an open circuit whose OpenDuration passed reports half-open
+func (b *CircuitBreaker) State(host string) CircuitState {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c, ok := b.hosts[host]
+	if !ok {
+		return CircuitClosed
+	}
+	if c.state == CircuitOpen && time.Since(c.openedAt) >= b.opts.OpenDuration {
+		return CircuitHalfOpen
+	}
+	return c.state
+}
+
+func (b *CircuitBreaker) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			host := req.URL.Hostname()
+			gen, err := b.allow(host)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next(req)
+			if err != nil && errors.Is(err, context.Canceled) {
+				b.done(host, gen, false, false)
+			} else {
+				b.done(host, gen, true, b.opts.IsFailure(resp, err))
+			}
+			return resp, err
+		}
+	}
+}
+
+func (b *CircuitBreaker) allow(host string) (uint64, error) {
+	var changes []stateChange
+	defer func() { b.notify(changes) }()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	if now.Sub(b.swept) >= b.sweepEvery {
+		b.swept = now
+		b.evictIdle(now)
+	}
+	c, ok := b.hosts[host]
+	if !ok {
+		c = &circuit{windowStart: now}
+		b.hosts[host] = c
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		if now.Sub(c.windowStart) >= b.opts.Window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < b.opts.OpenDuration {
+			return 0, &CircuitOpenError{Host: host, State: CircuitOpen}
+		}
+		changes = append(changes, b.transition(host, c, CircuitHalfOpen))
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes+c.successes >= b.opts.HalfOpenProbes {
+			return 0, &CircuitOpenError{Host: host, State: CircuitHalfOpen}
+		}
+		c.probes++
+	}
+	c.active++
+	return c.generation, nil
+}
+
+// evictIdle drop the closed circuits whose window ended and with nothing in flight, b.lock must be held
+func (b *CircuitBreaker) evictIdle(now time.Time) {
+	for host, c := range b.hosts {
+		if c.state == CircuitClosed && c.active == 0 && now.Sub(c.windowStart) >= b.opts.Window {
+			delete(b.hosts, host)
+		}
+	}
+}
+
+// done record the outcome of a request admitted in generation gen, counted is false when the
+// caller gave up so the exchange says nothing about the upstream
+func (b *CircuitBreaker) done(host string, gen uint64, counted, failed bool) {
+	var changes []stateChange
+	defer func() { b.notify(changes) }()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	c := b.hosts[host]
+	c.active--
+	if c.generation != gen {
+		return
+	}
+
+	switch c.state {
+	case CircuitClosed:
+		if !counted {
+			return
+		}
+		c.requests++
+		if failed {
+			c.failures++
+		}
+		if c.requests >= b.opts.MinRequests && float64(c.failures)/float64(c.requests) >= b.opts.FailureRatio {
+			changes = append(changes, b.transition(host, c, CircuitOpen))
+		}
+	case CircuitHalfOpen:
+		c.probes--
+		switch {
+		case !counted:
+		case failed:
+			changes = append(changes, b.transition(host, c, CircuitOpen))
+		default:
+			c.successes++
+			if c.successes >= b.opts.HalfOpenProbes {
+				changes = append(changes, b.transition(host, c, CircuitClosed))
+			}
+		}
+	}
+}
+
+func (b *CircuitBreaker) transition(host string, c *circuit, to CircuitState) stateChange {
+	from := c.state
+	now := time.Now()
+	c.state = to
+	c.generation++
+	c.probes, c.successes = 0, 0
+	switch to {
+	case CircuitOpen:
+		c.openedAt = now
+	case CircuitClosed:
+		c.windowStart, c.requests, c.failures = now, 0, 0
+	}
+	return stateChange{host: host, from: from, to: to}
+}
+
+// notify run the callback outside the lock so it may call State
+func (b *CircuitBreaker) notify(changes []stateChange) {
+	if b.opts.OnStateChange == nil {
+		return
+	}
+	for _, ch := range changes {
+		b.opts.OnStateChange(ch.host, ch.from, ch.to)
+	}
+}
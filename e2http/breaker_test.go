@@ -0,0 +1,99 @@
+package e2http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	host := u.Hostname()
+
+	var changes []string
+	b := NewCircuitBreaker(BreakerOptions{
+		FailureRatio:   0.5,
+		MinRequests:    4,
+		OpenDuration:   50 * time.Millisecond,
+		HalfOpenProbes: 2,
+		OnStateChange: func(host string, from, to CircuitState) {
+			changes = append(changes, fmt.Sprintf("%s->%s", from, to))
+		},
+	})
+	client := NewClient().Use(b.Middleware())
+
+	for range 4 {
+		client.Request(context.TODO()).URL(srv.URL).Do()
+	}
+	assert.Equal(t, CircuitOpen, b.State(host))
+	assert.Equal(t, 4, hits)
+
+	r := client.Request(context.TODO()).URL(srv.URL).Do()
+	assert.True(t, errors.Is(r.Err(), ErrCircuitOpen))
+	var ce *CircuitOpenError
+	assert.True(t, errors.As(r.Err(), &ce))
+	assert.Equal(t, host, ce.Host)
+	assert.Equal(t, 4, hits)
+
+	// a failed probe opens the circuit again
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, CircuitHalfOpen, b.State(host))
+	client.Request(context.TODO()).URL(srv.URL).Do()
+	assert.Equal(t, CircuitOpen, b.State(host))
+
+	// both probes have to succeed to close it
+	time.Sleep(60 * time.Millisecond)
+	status = http.StatusOK
+	assert.NoError(t, client.Request(context.TODO()).URL(srv.URL).Do().Err())
+	assert.Equal(t, CircuitHalfOpen, b.State(host))
+	assert.NoError(t, client.Request(context.TODO()).URL(srv.URL).Do().Err())
+	assert.Equal(t, CircuitClosed, b.State(host))
+
+	assert.Equal(t, []string{
+		"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed",
+	}, changes)
+}
+
+func TestCircuitBreakerEvictIdle(t *testing.T) {
+	b := NewCircuitBreaker(BreakerOptions{Window: 10 * time.Millisecond, MinRequests: 1, FailureRatio: 1, OpenDuration: time.Hour})
+	b.sweepEvery = 0
+
+	gen, err := b.allow("a")
+	assert.NoError(t, err)
+	b.done("a", gen, true, false)
+	genB, err := b.allow("b")
+	assert.NoError(t, err)
+	gen, err = b.allow("c")
+	assert.NoError(t, err)
+	b.done("c", gen, true, true)
+	assert.Equal(t, CircuitOpen, b.State("c"))
+
+	// a is closed and idle, b is in flight, c is open
+	time.Sleep(20 * time.Millisecond)
+	_, err = b.allow("d")
+	assert.NoError(t, err)
+	assert.NotContains(t, b.hosts, "a")
+	assert.Contains(t, b.hosts, "b")
+	assert.Equal(t, CircuitOpen, b.State("c"))
+
+	b.done("b", genB, true, false)
+	time.Sleep(20 * time.Millisecond)
+	_, err = b.allow("a")
+	assert.NoError(t, err)
+	assert.NotContains(t, b.hosts, "b")
+	assert.Equal(t, CircuitClosed, b.State("a"))
+}
@@ -0,0 +1,335 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/e2u/e2util/e2cache"
+	"github.com/eko/gocache/lib/v4/store"
+	"github.com/sirupsen/logrus"
+)
+
+// ResponseCache is an HTTP cache honouring Cache-Control, Expires, ETag and Last-Modified,
+// entries are kept in an e2cache store so both memory and redis work.
+// Responses to requests with Authorization are only stored when marked public, s-maxage or
+// must-revalidate, and a successful POST, PUT, PATCH or DELETE invalidates the entries of its URL
+type ResponseCache struct {
+	store        *e2cache.Connect
+	KeyPrefix    string
+	StaleTTL     time.Duration // how long a stale entry with validators is kept for revalidation
+	MaxEntrySize int64         // larger bodies are passed through without caching
+	// Shared follow the shared cache rules and never store Cache-Control: private responses,
+	// default true for a redis store, which other processes read too
+	Shared bool
+}
+
+func NewResponseCache(store *e2cache.Connect) *ResponseCache {
+	return &ResponseCache{
+		store:        store,
+		KeyPrefix:    "e2http:",
+		StaleTTL:     24 * time.Hour,
+		MaxEntrySize: 10 << 20,
+		Shared:       store != nil && store.Redis() != nil,
+	}
+}
+
+type cacheEntry struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	Vary       http.Header `json:"vary,omitempty"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+var cacheableStatus = []int{
+	http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+	http.StatusMovedPermanently, http.StatusPermanentRedirect, http.StatusNotFound, http.StatusMethodNotAllowed,
+	http.StatusGone, http.StatusRequestURITooLong, http.StatusNotImplemented,
+}
+
+// Cache serve GET/HEAD responses from c when fresh and revalidate them when stale
+func (r *Context) Cache(c *ResponseCache) *Context {
+	return r.Use(c.Middleware())
+}
+
+// FromCache report whether the response was served from the cache, including after a 304 revalidation
+func (r *Context) FromCache() bool {
+	return r.fromCache
+}
+
+func (c *ResponseCache) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			if c.store == nil || c.store.Err != nil || c.store.Cache == nil {
+				return next(req)
+			}
+			switch req.Method {
+			case http.MethodGet, http.MethodHead:
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+				resp, err := next(req)
+				if err == nil && resp.StatusCode < http.StatusBadRequest {
+					c.invalidate(req, resp)
+				}
+				return resp, err
+			default:
+				return next(req)
+			}
+			reqCC := parseCacheControl(req.Header)
+			if _, ok := reqCC["no-store"]; ok {
+				return next(req)
+			}
+
+			key := c.key(req.Method, req.URL)
+			entry := c.load(req.Context(), key)
+			if entry != nil && !entry.varyMatch(req) {
+				entry = nil
+			}
+
+			if entry != nil {
+				_, noCache := reqCC["no-cache"]
+				if !noCache && entry.fresh() {
+					markFromCache(req)
+					return entry.response(req), nil
+				}
+				if etag := entry.Header.Get("ETag"); etag != "" {
+					req = req.Clone(req.Context())
+					req.Header.Set("If-None-Match", etag)
+				} else if lm := entry.Header.Get("Last-Modified"); lm != "" {
+					req = req.Clone(req.Context())
+					req.Header.Set("If-Modified-Since", lm)
+				}
+			}
+
+			resp, err := next(req)
+			if err != nil {
+				return resp, err
+			}
+
+			if entry != nil && resp.StatusCode == http.StatusNotModified {
+				drainBody(resp.Body)
+				for k, vs := range resp.Header {
+					entry.Header[k] = vs
+				}
+				entry.StoredAt = time.Now()
+				c.save(req.Context(), key, entry)
+				markFromCache(req)
+				return entry.response(req), nil
+			}
+
+			return c.tryStore(req, key, resp), nil
+		}
+	}
+}
+
+// tryStore cache resp when allowed, the returned response always carries the full body
+func (c *ResponseCache) tryStore(req *http.Request, key string, resp *http.Response) *http.Response {
+	if !slices.Contains(cacheableStatus, resp.StatusCode) {
+		return resp
+	}
+	respCC := parseCacheControl(resp.Header)
+	if _, ok := respCC["no-store"]; ok || resp.Header.Get("Vary") == "*" {
+		return resp
+	}
+	if _, ok := respCC["private"]; ok && c.Shared {
+		return resp
+	}
+	if req.Header.Get("Authorization") != "" && !explicitlyShareable(respCC) {
+		return resp
+	}
+	entry := &cacheEntry{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		StoredAt:   time.Now(),
+	}
+	lifetime := entry.lifetime()
+	hasValidator := entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != ""
+	if lifetime <= 0 && !hasValidator {
+		return resp
+	}
+	if resp.ContentLength > c.MaxEntrySize {
+		return resp
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.MaxEntrySize+1))
+	if err != nil || int64(len(body)) > c.MaxEntrySize {
+		resp.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), resp.Body), Closer: resp.Body}
+		return resp
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	entry.Body = body
+
+	for _, name := range strings.Split(resp.Header.Get("Vary"), ",") {
+		if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" {
+			if entry.Vary == nil {
+				entry.Vary = make(http.Header)
+			}
+			entry.Vary[name] = req.Header.Values(name)
+		}
+	}
+
+	c.save(req.Context(), key, entry)
+	return resp
+}
+
+func (c *ResponseCache) key(method string, u *url.URL) string {
+	return c.KeyPrefix + method + " " + u.String()
+}
+
+// invalidate drop the entries of the request URL and of the same origin Location and
+// Content-Location of resp, RFC 9111 section 4.4
+func (c *ResponseCache) invalidate(req *http.Request, resp *http.Response) {
+	urls := []*url.URL{req.URL}
+	for _, name := range []string{"Location", "Content-Location"} {
+		if v := resp.Header.Get(name); v != "" {
+			if u, err := req.URL.Parse(v); err == nil && u.Scheme == req.URL.Scheme && u.Host == req.URL.Host {
+				urls = append(urls, u)
+			}
+		}
+	}
+	for _, u := range urls {
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			if err := c.store.Delete(req.Context(), c.key(method, u)); err != nil {
+				logrus.Debugf("e2http: invalidate cache entry error=%v, url=%s", err, u)
+			}
+		}
+	}
+}
+
+// explicitlyShareable report whether a response to a request with Authorization may be stored,
+// RFC 9111 section 3.5
+func explicitlyShareable(cc map[string]string) bool {
+	for _, d := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := cc[d]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ResponseCache) load(ctx context.Context, key string) *cacheEntry {
+	v, err := c.store.Get(ctx, key)
+	if err != nil || v == nil {
+		return nil
+	}
+	var b []byte
+	switch tv := v.(type) {
+	case []byte:
+		b = tv
+	case string:
+		b = []byte(tv)
+	default:
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		logrus.Warnf("e2http: decode cache entry error=%v, key=%s", err, key)
+		return nil
+	}
+	return &entry
+}
+
+func (c *ResponseCache) save(ctx context.Context, key string, entry *cacheEntry) {
+	ttl := max(entry.lifetime(), 0)
+	if entry.Header.Get("ETag") != "" || entry.Header.Get("Last-Modified") != "" {
+		ttl += c.StaleTTL
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := c.store.Set(ctx, key, b, store.WithExpiration(ttl)); err != nil {
+		logrus.Warnf("e2http: save cache entry error=%v, key=%s", err, key)
+	}
+}
+
+// lifetime is the freshness lifetime: max-age, then Expires, then 10% of the Last-Modified age
+func (e *cacheEntry) lifetime() time.Duration {
+	cc := parseCacheControl(e.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if v, ok := cc["max-age"]; ok {
+		if sec, err := strconv.Atoi(v); err == nil {
+			return time.Duration(sec) * time.Second
+		}
+	}
+	date, err := http.ParseTime(e.Header.Get("Date"))
+	if err != nil {
+		date = e.StoredAt
+	}
+	if v := e.Header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(date)
+	}
+	if lm, err := http.ParseTime(e.Header.Get("Last-Modified")); err == nil && date.After(lm) {
+		return min(date.Sub(lm)/10, 24*time.Hour)
+	}
+	return 0
+}
+
+func (e *cacheEntry) fresh() bool {
+	age := time.Since(e.StoredAt)
+	if v, err := strconv.Atoi(e.Header.Get("Age")); err == nil {
+		age += time.Duration(v) * time.Second
+	}
+	return age < e.lifetime()
+}
+
+func (e *cacheEntry) varyMatch(req *http.Request) bool {
+	for name, vs := range e.Vary {
+		if !slices.Equal(req.Header.Values(name), vs) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func parseCacheControl(h http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name != "" {
+				cc[strings.ToLower(name)] = strings.Trim(value, `"`)
+			}
+		}
+	}
+	return cc
+}
+
+func markFromCache(req *http.Request) {
+	if rc := contextFromRequest(req); rc != nil {
+		rc.fromCache = true
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,148 @@
+package e2http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/e2u/e2util/e2cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	var hits, revalidated int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch req.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if req.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&revalidated, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		_, _ = w.Write([]byte("body of " + req.URL.Path))
+	}))
+	defer srv.Close()
+
+	rc := NewResponseCache(e2cache.New(&e2cache.Config{Type: "memory"}))
+	cli := NewClient(DefaultClientOptions().WithBaseURL(srv.URL))
+	cli.Use(rc.Middleware())
+
+	r := cli.Request(context.TODO()).URL("/fresh").Do()
+	assert.False(t, r.FromCache())
+	r = cli.Request(context.TODO()).URL("/fresh").Do()
+	assert.True(t, r.FromCache())
+	assert.Equal(t, "body of /fresh", r.BodyString())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	r = cli.Request(context.TODO()).URL("/etag").Do()
+	assert.False(t, r.FromCache())
+	r = cli.Request(context.TODO()).URL("/etag").Do()
+	assert.True(t, r.FromCache())
+	assert.Equal(t, http.StatusOK, r.StatusCode())
+	assert.Equal(t, "body of /etag", r.BodyString())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&revalidated))
+}
+
+func TestResponseCacheAuthorization(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch req.URL.Path {
+		case "/me":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		}
+		_, _ = w.Write([]byte(req.URL.Path + " of " + req.Header.Get("Authorization")))
+	}))
+	defer srv.Close()
+
+	rc := NewResponseCache(e2cache.New(&e2cache.Config{Type: "memory"}))
+	cli := NewClient(DefaultClientOptions().WithBaseURL(srv.URL))
+	cli.Use(rc.Middleware())
+
+	// not marked shareable, every user gets its own response
+	r := cli.Request(context.TODO()).URL("/me").SetHeader("Authorization", "Bearer alice").Do()
+	assert.Equal(t, "/me of Bearer alice", r.BodyString())
+	r = cli.Request(context.TODO()).URL("/me").SetHeader("Authorization", "Bearer bob").Do()
+	assert.False(t, r.FromCache())
+	assert.Equal(t, "/me of Bearer bob", r.BodyString())
+
+	cli.Request(context.TODO()).URL("/public").SetHeader("Authorization", "Bearer alice").Do()
+	r = cli.Request(context.TODO()).URL("/public").SetHeader("Authorization", "Bearer bob").Do()
+	assert.True(t, r.FromCache())
+
+	// private responses stay out of a shared store
+	rc.Shared = true
+	cli.Request(context.TODO()).URL("/private").Do()
+	r = cli.Request(context.TODO()).URL("/private").Do()
+	assert.False(t, r.FromCache())
+
+	rc.Shared = false
+	cli.Request(context.TODO()).URL("/private").Do()
+	r = cli.Request(context.TODO()).URL("/private").Do()
+	assert.True(t, r.FromCache())
+	assert.Equal(t, int32(6), atomic.LoadInt32(&hits))
+}
+
+func TestResponseCacheInvalidate(t *testing.T) {
+	var version int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			w.Header().Set("Cache-Control", "max-age=60")
+			_, _ = fmt.Fprintf(w, "%s v%d", req.URL.Path, atomic.LoadInt32(&version))
+		case http.MethodPost:
+			if req.URL.Path == "/fail" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			atomic.AddInt32(&version, 1)
+			w.Header().Set("Location", "/items/1")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			atomic.AddInt32(&version, 1)
+		}
+	}))
+	defer srv.Close()
+
+	rc := NewResponseCache(e2cache.New(&e2cache.Config{Type: "memory"}))
+	cli := NewClient(DefaultClientOptions().WithBaseURL(srv.URL))
+	cli.Use(rc.Middleware())
+	get := func(path string) *Context {
+		return cli.Request(context.TODO()).URL(path).Do()
+	}
+
+	get("/items")
+	get("/items/1")
+	assert.True(t, get("/items").FromCache())
+
+	// the POST URL and its Location are invalidated
+	assert.NoError(t, cli.Request(context.TODO()).Method(http.MethodPost).URL("/items").Do().Err())
+	r := get("/items")
+	assert.False(t, r.FromCache())
+	assert.Equal(t, "/items v1", r.BodyString())
+	assert.Equal(t, "/items/1 v1", get("/items/1").BodyString())
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		assert.True(t, get("/items/1").FromCache())
+		cli.Request(context.TODO()).Method(method).URL("/items/1").Do()
+		assert.False(t, get("/items/1").FromCache(), method)
+	}
+
+	// error responses leave the entry alone
+	get("/fail")
+	cli.Request(context.TODO()).Method(http.MethodPost).URL("/fail").Do()
+	assert.True(t, get("/fail").FromCache())
+}
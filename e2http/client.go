@@ -0,0 +1,207 @@
+package e2http
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ClientOptions hold the defaults shared by every request made through a Client
+type ClientOptions struct {
+	BaseURL             string
+	Headers             map[string]string
+	Timeout             time.Duration // whole request, including reading the body
+	ConnectTimeout      time.Duration
+	Proxy               string // e.g. socks5://127.0.0.1:1080, http://127.0.0.1:3128
+	TLSConfig           *tls.Config
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	CookieJar           http.CookieJar // shared by all requests of the client, e.g. NewCookieJar()
+	// Resolve pin hosts to addresses like curl --resolve, keys are "host" or "host:port"
+	Resolve       map[string][]string
+	Resolver      Resolver      // e.g. NewCachingResolver(nil, time.Minute)
+	FallbackDelay time.Duration // Happy Eyeballs delay, negative disables it
+	UnixSocket    string        // dial every connection to this socket, e.g. /var/run/docker.sock
+}
+
+func DefaultClientOptions() ClientOptions {
+	return ClientOptions{
+		ConnectTimeout:      30 * time.Second,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+	}
+}
+
+func (opt ClientOptions) WithBaseURL(u string) ClientOptions {
+	opt.BaseURL = u
+	return opt
+}
+
+func (opt ClientOptions) WithHeader(key, value string) ClientOptions {
+	headers := make(map[string]string, len(opt.Headers)+1)
+	for k, v := range opt.Headers {
+		headers[k] = v
+	}
+	headers[key] = value
+	opt.Headers = headers
+	return opt
+}
+
+func (opt ClientOptions) WithTimeout(timeout time.Duration) ClientOptions {
+	opt.Timeout = timeout
+	return opt
+}
+
+func (opt ClientOptions) WithConnectTimeout(timeout time.Duration) ClientOptions {
+	opt.ConnectTimeout = timeout
+	return opt
+}
+
+func (opt ClientOptions) WithProxy(p string) ClientOptions {
+	opt.Proxy = p
+	return opt
+}
+
+func (opt ClientOptions) WithTLSConfig(c *tls.Config) ClientOptions {
+	opt.TLSConfig = c
+	return opt
+}
+
+func (opt ClientOptions) WithCookieJar(jar http.CookieJar) ClientOptions {
+	opt.CookieJar = jar
+	return opt
+}
+
+func (opt ClientOptions) WithResolve(host string, addrs ...string) ClientOptions {
+	resolve := make(map[string][]string, len(opt.Resolve)+1)
+	for k, v := range opt.Resolve {
+		resolve[k] = v
+	}
+	resolve[host] = addrs
+	opt.Resolve = resolve
+	return opt
+}
+
+func (opt ClientOptions) WithResolver(res Resolver) ClientOptions {
+	opt.Resolver = res
+	return opt
+}
+
+func (opt ClientOptions) WithFallbackDelay(d time.Duration) ClientOptions {
+	opt.FallbackDelay = d
+	return opt
+}
+
+func (opt ClientOptions) WithUnixSocket(path string) ClientOptions {
+	opt.UnixSocket = path
+	return opt
+}
+
+func (opt ClientOptions) WithMaxConnsPerHost(n int) ClientOptions {
+	opt.MaxConnsPerHost = n
+	return opt
+}
+
+// Client keep one pooled transport, requests created by Client.Request reuse its connections
+type Client struct {
+	opts        ClientOptions
+	baseURL     *url.URL
+	transport   *http.Transport
+	dial        *dialConfig
+	middlewares []Middleware
+	err         error
+}
+
+var defaultClient = NewClient()
+
+func NewClient(opts ...ClientOptions) *Client {
+	opt := DefaultClientOptions()
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	c := &Client{
+		opts:      opt,
+		transport: http.DefaultTransport.(*http.Transport).Clone(),
+	}
+
+	t := c.transport
+	// same defaults as http.DefaultTransport
+	c.dial = newDialConfig(30 * time.Second)
+	if opt.ConnectTimeout > 0 {
+		c.dial.timeout = opt.ConnectTimeout
+	}
+	for host, addrs := range opt.Resolve {
+		if err := c.dial.addResolve(host, addrs...); err != nil {
+			c.err = err
+		}
+	}
+	c.dial.resolver = opt.Resolver
+	c.dial.fallbackDelay = opt.FallbackDelay
+	c.dial.unixSocket = opt.UnixSocket
+	t.DialContext = c.dial.DialContext
+	if opt.MaxIdleConns > 0 {
+		t.MaxIdleConns = opt.MaxIdleConns
+	}
+	if opt.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = opt.MaxIdleConnsPerHost
+	}
+	if opt.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = opt.MaxConnsPerHost
+	}
+	if opt.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = opt.IdleConnTimeout
+	}
+	if opt.TLSConfig != nil {
+		t.TLSClientConfig = opt.TLSConfig.Clone()
+	}
+	if opt.Proxy != "" {
+		if proxyUrl, err := url.Parse(opt.Proxy); err == nil {
+			t.Proxy = http.ProxyURL(proxyUrl)
+		} else {
+			c.err = err
+		}
+	}
+	if opt.BaseURL != "" {
+		if u, err := url.Parse(opt.BaseURL); err == nil {
+			c.baseURL = u
+		} else {
+			c.err = err
+		}
+	}
+	return c
+}
+
+// Use add middlewares to every request of the client, they run after the process-wide defaults
+func (c *Client) Use(mw ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, mw...)
+	return c
+}
+
+// Request start a fluent request sharing the client's connection pool
+func (c *Client) Request(ctx context.Context) *Context {
+	r := &Context{
+		client:     c,
+		cli:        &http.Client{Timeout: c.opts.Timeout, Jar: c.opts.CookieJar},
+		ctx:        ctx,
+		method:     http.MethodGet,
+		reqHeaders: make(map[string][]string),
+		baseURL:    c.baseURL,
+	}
+	for k, v := range c.opts.Headers {
+		r.reqHeaders.Set(k, v)
+	}
+	if c.err != nil {
+		r.appendErr(c.err)
+	}
+	return r
+}
+
+func (c *Client) CloseIdleConnections() {
+	c.transport.CloseIdleConnections()
+}
@@ -0,0 +1,36 @@
+package e2http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientReuseConnections(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(req.URL.Path + " " + req.Header.Get("X-App")))
+	}))
+	srv.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	cli := NewClient(DefaultClientOptions().WithBaseURL(srv.URL).WithHeader("X-App", "e2http"))
+	defer cli.CloseIdleConnections()
+
+	for i := 0; i < 5; i++ {
+		r := cli.Request(context.TODO()).URL("/ping").Do()
+		assert.Empty(t, r.Errors())
+		assert.Equal(t, "/ping e2http", r.BodyString())
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
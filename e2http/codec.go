@@ -0,0 +1,367 @@
+package e2http
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec marshal request bodies and unmarshal response bodies of one media type
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// UnsupportedMediaTypeError is returned by Decode when no codec is registered for the response Content-Type
+type UnsupportedMediaTypeError struct {
+	MediaType string
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	return "e2http: no codec registered for media type " + e.MediaType
+}
+
+var (
+	codecs     = map[string]Codec{}
+	codecOrder []string // registration order, used to build the Accept header of Decode
+	codecsLock sync.RWMutex
+)
+
+func init() {
+	msgpackCodec := msgpackMarshaler{handle: &codec.MsgpackHandle{WriteExt: true}}
+	RegisterCodec("application/json", jsonMarshaler{})
+	RegisterCodec("application/xml", xmlMarshaler{})
+	RegisterCodec("text/xml", xmlMarshaler{})
+	RegisterCodec("application/msgpack", msgpackCodec)
+	RegisterCodec("application/x-msgpack", msgpackCodec)
+	RegisterCodec("application/vnd.msgpack", msgpackCodec)
+	RegisterCodec("application/protobuf", protobufMarshaler{})
+	RegisterCodec("application/x-protobuf", protobufMarshaler{})
+	RegisterCodec("application/x-www-form-urlencoded", formMarshaler{})
+}
+
+// RegisterCodec make Decode and Encode handle mediaType with c, it replaces any codec already
+// registered for the same media type, e.g. RegisterCodec("application/vnd.acme+cbor", cborCodec)
+func RegisterCodec(mediaType string, c Codec) {
+	mediaType = strings.ToLower(mediaType)
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	if _, ok := codecs[mediaType]; !ok {
+		codecOrder = append(codecOrder, mediaType)
+	}
+	codecs[mediaType] = c
+}
+
+// lookupCodec find the codec of a Content-Type, +json and +xml structured suffixes fall back to JSON and XML
+func lookupCodec(contentType string) (Codec, error) {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		if contentType != "" {
+			return nil, &UnsupportedMediaTypeError{MediaType: contentType}
+		}
+		mt = "application/json"
+	}
+
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	if c, ok := codecs[mt]; ok {
+		return c, nil
+	}
+	switch {
+	case strings.HasSuffix(mt, "+json"):
+		return codecs["application/json"], nil
+	case strings.HasSuffix(mt, "+xml"):
+		return codecs["application/xml"], nil
+	}
+	return nil, &UnsupportedMediaTypeError{MediaType: mt}
+}
+
+// acceptHeader list the registered media types, JSON first
+func acceptHeader() string {
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+	types := make([]string, 0, len(codecOrder))
+	for i, mt := range codecOrder {
+		if i > 0 {
+			mt += ";q=0.9"
+		}
+		types = append(types, mt)
+	}
+	return strings.Join(types, ", ")
+}
+
+// Decode unmarshal the response body into v with the codec matching the response Content-Type,
+// a missing Content-Type is treated as JSON; called before Do it also sets Accept to the registered
+// media types unless an Accept header is already set
+func (r *Context) Decode(v any) *Context {
+	if len(r.respBody) != 0 {
+		if err := r.decode(r.respHeaders.Get("Content-Type"), r.respBody, v); err != nil {
+			r.appendErr(err)
+		}
+		return r
+	}
+	r.decodePointer = v
+	if r.reqHeaders.Get("Accept") == "" {
+		r.reqHeaders.Set("Accept", acceptHeader())
+	}
+	return r
+}
+
+func (r *Context) decode(contentType string, b []byte, v any) error {
+	c, err := lookupCodec(contentType)
+	if err != nil {
+		return err
+	}
+	return c.Unmarshal(b, v)
+}
+
+// Encode marshal v with the codec of mediaType as the request body, an io.Reader is sent as is
+func (r *Context) Encode(mediaType string, v any) *Context {
+	r.ContentType(mediaType)
+	if rd, ok := v.(io.Reader); ok {
+		r.reqBody = rd
+		return r
+	}
+	c, err := lookupCodec(mediaType)
+	if err != nil {
+		r.appendErr(err)
+		return r
+	}
+	b, err := c.Marshal(v)
+	if err != nil {
+		r.appendErr(err)
+		return r
+	}
+	r.reqBody = bytes.NewBuffer(b)
+	return r
+}
+
+func (r *Context) PostXML(v any) *Context {
+	return r.Method(http.MethodPost).Encode("application/xml", v)
+}
+
+func (r *Context) PostMsgpack(v any) *Context {
+	return r.Method(http.MethodPost).Encode("application/msgpack", v)
+}
+
+type jsonMarshaler struct{}
+
+func (jsonMarshaler) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (jsonMarshaler) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type xmlMarshaler struct{}
+
+func (xmlMarshaler) Marshal(v any) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlMarshaler) Unmarshal(data []byte, v any) error { return xml.Unmarshal(data, v) }
+
+type msgpackMarshaler struct {
+	handle *codec.MsgpackHandle
+}
+
+func (m msgpackMarshaler) Marshal(v any) ([]byte, error) {
+	var b []byte
+	err := codec.NewEncoderBytes(&b, m.handle).Encode(v)
+	return b, err
+}
+
+func (m msgpackMarshaler) Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, m.handle).Decode(v)
+}
+
+type protobufMarshaler struct{}
+
+func (protobufMarshaler) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("e2http: protobuf codec wants a proto.Message, got %T", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufMarshaler) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("e2http: protobuf codec wants a proto.Message, got %T", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// formMarshaler handle url.Values, map[string]string, map[string][]string and structs with url tags
+type formMarshaler struct{}
+
+func (formMarshaler) Marshal(v any) ([]byte, error) {
+	switch x := v.(type) {
+	case url.Values:
+		return []byte(x.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(x).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(x))
+		for k, s := range x {
+			values.Set(k, s)
+		}
+		return []byte(values.Encode()), nil
+	}
+	values := make(url.Values)
+	if err := encodeQueryStruct(values, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return []byte(values.Encode()), nil
+}
+
+func (formMarshaler) Unmarshal(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch x := v.(type) {
+	case *url.Values:
+		*x = values
+		return nil
+	case *map[string][]string:
+		*x = values
+		return nil
+	case *map[string]string:
+		*x = make(map[string]string, len(values))
+		for k := range values {
+			(*x)[k] = values.Get(k)
+		}
+		return nil
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("e2http: form codec wants a non-nil pointer, got %T", v)
+	}
+	return decodeFormStruct(values, rv.Elem())
+}
+
+// decodeFormStruct is the reverse of encodeQueryStruct
+func decodeFormStruct(values url.Values, rv reflect.Value) error {
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("e2http: form codec wants a struct, got %s", rv.Kind())
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fv := rv.Field(i)
+
+		if sf.Anonymous && name == "" {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			if err := decodeFormStruct(values, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() != reflect.Uint8 {
+			slice := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+			for j, s := range vs {
+				if err := setFormValue(slice.Index(j), s); err != nil {
+					return fmt.Errorf("e2http: form field %s: %w", name, err)
+				}
+			}
+			fv.Set(slice)
+			continue
+		}
+		if err := setFormValue(fv, vs[0]); err != nil {
+			return fmt.Errorf("e2http: form field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setFormValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	if v.CanAddr() {
+		switch x := v.Addr().Interface().(type) {
+		case *time.Time:
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return err
+			}
+			*x = t
+			return nil
+		case encoding.TextUnmarshaler:
+			return x.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
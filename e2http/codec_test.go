@@ -0,0 +1,76 @@
+package e2http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type codecItem struct {
+	Name  string   `json:"name" xml:"name" url:"name" codec:"name"`
+	Count int      `json:"count" xml:"count" url:"count" codec:"count"`
+	Tags  []string `json:"tags" xml:"tags" url:"tag" codec:"tags"`
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v any) ([]byte, error) { return []byte(strings.ToUpper(v.(string))), nil }
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	*v.(*string) = strings.ToLower(string(data))
+	return nil
+}
+
+func TestDecode(t *testing.T) {
+	// echo the request body back with the request Content-Type
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", req.Header.Get("Content-Type"))
+		w.Header().Set("X-Accept", req.Header.Get("Accept"))
+		_, _ = io.Copy(w, req.Body)
+	}))
+	defer srv.Close()
+
+	want := codecItem{Name: "a", Count: 2, Tags: []string{"x", "y"}}
+
+	var fromXML codecItem
+	r := Builder(context.TODO()).URL(srv.URL).PostXML(want).Decode(&fromXML).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, want, fromXML)
+	assert.True(t, strings.HasPrefix(r.Headers().Get("X-Accept"), "application/json, application/xml;q=0.9"))
+
+	var fromMsgpack codecItem
+	r = Builder(context.TODO()).URL(srv.URL).PostMsgpack(want).Decode(&fromMsgpack).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, want, fromMsgpack)
+
+	var fromForm codecItem
+	r = Builder(context.TODO()).URL(srv.URL).Method(http.MethodPost).Encode("application/x-www-form-urlencoded", want).Do()
+	assert.Equal(t, "count=2&name=a&tag=x&tag=y", r.BodyString())
+	r.Decode(&fromForm)
+	assert.NoError(t, r.Err())
+	assert.Equal(t, want, fromForm)
+
+	var fromProto wrapperspb.StringValue
+	r = Builder(context.TODO()).URL(srv.URL).Method(http.MethodPost).Encode("application/x-protobuf", wrapperspb.String("hi")).Decode(&fromProto).Do()
+	assert.NoError(t, r.Err())
+	assert.True(t, proto.Equal(wrapperspb.String("hi"), &fromProto))
+
+	RegisterCodec("application/vnd.e2u.upper", upperCodec{})
+	var s string
+	r = Builder(context.TODO()).URL(srv.URL).Method(http.MethodPost).Encode("application/vnd.e2u.upper", "hello").Decode(&s).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "HELLO", r.BodyString())
+	assert.Equal(t, "hello", s)
+
+	r = Builder(context.TODO()).URL(srv.URL).Method(http.MethodPost).ContentType("text/csv").PostRaw(strings.NewReader("a,b")).Decode(&s).Do()
+	var ue *UnsupportedMediaTypeError
+	assert.True(t, errors.As(r.Err(), &ue))
+	assert.Equal(t, "text/csv", ue.MediaType)
+}
@@ -0,0 +1,168 @@
+package e2http
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar is a net/http/cookiejar which remembers what it stored, so a session can be
+// saved to and loaded from a JSON file
+type CookieJar struct {
+	jar     *cookiejar.Jar
+	lock    sync.Mutex
+	entries map[string]*savedCookie
+}
+
+type savedCookie struct {
+	URL      string        `json:"url"`
+	Name     string        `json:"name"`
+	Value    string        `json:"value"`
+	Domain   string        `json:"domain,omitempty"`
+	Path     string        `json:"path,omitempty"`
+	Expires  time.Time     `json:"expires,omitzero"`
+	Secure   bool          `json:"secure,omitempty"`
+	HttpOnly bool          `json:"http_only,omitempty"`
+	SameSite http.SameSite `json:"same_site,omitempty"`
+}
+
+func NewCookieJar() *CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &CookieJar{
+		jar:     jar,
+		entries: make(map[string]*savedCookie),
+	}
+}
+
+// LoadCookieJar read a jar saved by CookieJar.Save, a missing file gives an empty jar
+func LoadCookieJar(path string) (*CookieJar, error) {
+	j := NewCookieJar()
+	b, err := os.ReadFile(path) // #nosec G304
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved []*savedCookie
+	if err := json.Unmarshal(b, &saved); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, sc := range saved {
+		if !sc.Expires.IsZero() && sc.Expires.Before(now) {
+			continue
+		}
+		u, err := url.Parse(sc.URL)
+		if err != nil {
+			return nil, err
+		}
+		j.SetCookies(u, []*http.Cookie{{
+			Name:     sc.Name,
+			Value:    sc.Value,
+			Domain:   sc.Domain,
+			Path:     sc.Path,
+			Expires:  sc.Expires,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HttpOnly,
+			SameSite: sc.SameSite,
+		}})
+	}
+	return j, nil
+}
+
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.jar.SetCookies(u, cookies)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		sc := &savedCookie{
+			URL:      (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String(),
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+			SameSite: c.SameSite,
+		}
+		if c.MaxAge > 0 {
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+
+		domain := c.Domain
+		if domain == "" {
+			domain = u.Hostname()
+		}
+		key := domain + ";" + c.Path + ";" + c.Name
+		if c.MaxAge < 0 || (!sc.Expires.IsZero() && sc.Expires.Before(now)) {
+			delete(j.entries, key)
+			continue
+		}
+		j.entries[key] = sc
+	}
+}
+
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// Save write the unexpired cookies to path as JSON
+func (j *CookieJar) Save(path string) error {
+	j.lock.Lock()
+	now := time.Now()
+	saved := make([]*savedCookie, 0, len(j.entries))
+	for _, sc := range j.entries {
+		if sc.Expires.IsZero() || sc.Expires.After(now) {
+			saved = append(saved, sc)
+		}
+	}
+	j.lock.Unlock()
+
+	slices.SortFunc(saved, func(a, b *savedCookie) int {
+		if a.URL != b.URL {
+			return cmp.Compare(a.URL, b.URL)
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	b, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// CookieJar share a jar between requests, cookies set by responses are sent on later requests
+func (r *Context) CookieJar(jar http.CookieJar) *Context {
+	r.cli.Jar = jar
+	return r
+}
+
+// SetCookies set the cookies sent with the request
+func (r *Context) SetCookies(c []*http.Cookie) *Context {
+	r.reqCookies = slices.Clone(c)
+	return r
+}
+
+func (r *Context) AddCookie(c *http.Cookie) *Context {
+	r.reqCookies = append(r.reqCookies, c)
+	return r
+}
@@ -0,0 +1,45 @@
+package e2http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieJar(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "s3cr3t", Path: "/", MaxAge: 3600})
+		default:
+			if c, err := req.Cookie("sid"); err == nil {
+				_, _ = w.Write([]byte(c.Value))
+			}
+		}
+	}))
+	defer srv.Close()
+
+	jar := NewCookieJar()
+	r := Builder(context.TODO()).CookieJar(jar).BaseURL(srv.URL).Path("/login").Do()
+	assert.Empty(t, r.Errors())
+	assert.Len(t, r.Cookies(), 1)
+
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	assert.NoError(t, jar.Save(path))
+
+	restored, err := LoadCookieJar(path)
+	assert.NoError(t, err)
+	r = Builder(context.TODO()).CookieJar(restored).BaseURL(srv.URL).Path("/me").Do()
+	assert.Equal(t, "s3cr3t", r.BodyString())
+
+	r = Builder(context.TODO()).
+		BaseURL(srv.URL).
+		Path("/me").
+		SetCookies([]*http.Cookie{{Name: "sid", Value: "manual"}}).
+		Do()
+	assert.Equal(t, "manual", r.BodyString())
+}
@@ -0,0 +1,464 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// AsCurl render the request as a curl command line, it can be called before or after Do;
+// a body which cannot be read twice is buffered so the request can still be sent, cookies of
+// the cookie jar matching the URL are included in the Cookie header
+func (r *Context) AsCurl() string {
+	u, err := r.requestURL()
+	if err != nil {
+		r.appendErr(err)
+		return ""
+	}
+
+	args := []string{"curl"}
+	header := r.reqHeaders.Clone()
+	for _, h := range r.delHeaders {
+		header.Del(h)
+	}
+	cookies := r.reqCookies
+	if r.cli != nil && r.cli.Jar != nil {
+		cookies = append(slices.Clip(cookies), r.cli.Jar.Cookies(u)...)
+	}
+	for _, c := range cookies {
+		if v := header.Get("Cookie"); v != "" {
+			header.Set("Cookie", v+"; "+c.String())
+		} else {
+			header.Set("Cookie", c.String())
+		}
+	}
+
+	var bodyArgs []string
+	switch b := r.reqBody.(type) {
+	case nil:
+	case *multipartBody:
+		header.Del("Content-Type") // curl sets it with its own boundary
+		for _, p := range b.parts {
+			field, err := curlFormField(p)
+			if err != nil {
+				r.appendErr(err)
+				return ""
+			}
+			bodyArgs = append(bodyArgs, "-F", shellQuote(field))
+		}
+	case *os.File:
+		bodyArgs = append(bodyArgs, "--data-binary", shellQuote("@"+b.Name()))
+	default:
+		body, err := r.peekBody()
+		if err != nil {
+			r.appendErr(err)
+			return ""
+		}
+		bodyArgs = append(bodyArgs, "--data-binary", shellQuote(string(body)))
+	}
+
+	switch {
+	case r.method == http.MethodGet && len(bodyArgs) == 0:
+	case r.method == http.MethodPost && len(bodyArgs) > 0:
+	case r.method == http.MethodHead:
+		// with -X HEAD curl waits for a body which never comes
+		args = append(args, "-I")
+	default:
+		args = append(args, "-X", r.method)
+	}
+	if user, pass, ok := r.BasicAuth(); ok {
+		header.Del("Authorization")
+		args = append(args, "-u", shellQuote(user+":"+pass))
+	}
+	for _, k := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[k] {
+			args = append(args, "-H", shellQuote(k+": "+v))
+		}
+	}
+	args = append(args, bodyArgs...)
+
+	if p := r.proxyURL; p != nil {
+		args = append(args, "-x", shellQuote(p.String()))
+	} else if r.client.opts.Proxy != "" {
+		args = append(args, "-x", shellQuote(r.client.opts.Proxy))
+	}
+	t := r.client.transport
+	if r.transport != nil {
+		t = r.transport
+	}
+	if t.TLSClientConfig != nil && t.TLSClientConfig.InsecureSkipVerify {
+		args = append(args, "-k")
+	}
+	args = append(args, shellQuote(u.String()))
+	return strings.Join(args, " ")
+}
+
+// peekBody read the request body and keep a replayable copy in its place
+func (r *Context) peekBody() ([]byte, error) {
+	if b, ok := r.reqBody.(*bytes.Buffer); ok {
+		return b.Bytes(), nil
+	}
+	b, err := io.ReadAll(r.reqBody)
+	closeReader(r.reqBody)
+	if err != nil {
+		return nil, err
+	}
+	r.reqBody = bytes.NewBuffer(b)
+	return b, nil
+}
+
+// curlFormField render a multipart part for -F, a value read from any other reader than a
+// buffer is read once and kept as a buffer so the request can still be sent
+func curlFormField(p *MultipartPart) (string, error) {
+	if p.FileName == "" {
+		var value string
+		switch rd := p.Reader.(type) {
+		case nil:
+		case *bytes.Buffer:
+			value = rd.String()
+		case *strings.Reader:
+			b := make([]byte, rd.Len())
+			_, _ = rd.ReadAt(b, rd.Size()-int64(rd.Len()))
+			value = string(b)
+		default:
+			b, err := io.ReadAll(rd)
+			closeReader(rd)
+			if err != nil {
+				return "", fmt.Errorf("e2http: read multipart field %s: %w", p.Name, err)
+			}
+			p.Reader = bytes.NewBuffer(b)
+			value = string(b)
+		}
+		return p.Name + "=" + value, nil
+	}
+	field := p.Name + "=@"
+	if f, ok := p.Reader.(*os.File); ok {
+		field += f.Name()
+	} else {
+		field += p.FileName
+	}
+	if p.ContentType != "" {
+		field += ";type=" + p.ContentType
+	}
+	return field, nil
+}
+
+// shellQuote quote s for a POSIX shell, non-printable input uses the $'...' form
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_./:=@,+%", c))
+	}) < 0 {
+		return s
+	}
+	printable := utf8.ValidString(s) && strings.IndexFunc(s, func(c rune) bool {
+		return c < 0x20 && c != '\t' || c == 0x7f
+	}) < 0
+	if printable {
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString("$'")
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20 || c >= 0x7f:
+			_, _ = fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// curlNoValueFlags are accepted by FromCurl and ignored, they do not change the request
+var curlNoValueFlags = []string{
+	"-s", "--silent", "-S", "--show-error", "-v", "--verbose", "-i", "--include",
+	"-L", "--location", "--compressed", "-g", "--globoff", "-f", "--fail",
+}
+
+// FromCurl build a request from a curl command line, the supported options are
+// -X, -H, -d (--data, --data-raw, --data-ascii), --data-binary, -u, -x, -k and -I;
+// a few output-only flags such as -s, -v, -L and --compressed are ignored, anything else is an error.
+// Combined short flags such as -sSL or -sXPOST are split first
+func FromCurl(ctx context.Context, cmd string) *Context {
+	r := Builder(ctx)
+	args, err := splitShellWords(cmd)
+	if err != nil {
+		r.appendErr(err)
+		return r
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	var (
+		method string
+		rawURL string
+		data   []string
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name, value, inline := curlOption(arg)
+
+		needValue := func() (string, bool) {
+			if inline {
+				return value, true
+			}
+			if i+1 >= len(args) {
+				r.appendErr(fmt.Errorf("e2http: curl option %s needs a value", name))
+				return "", false
+			}
+			i++
+			return args[i], true
+		}
+
+		switch name {
+		case "-X", "--request":
+			if v, ok := needValue(); ok {
+				method = v
+			}
+		case "-H", "--header":
+			if v, ok := needValue(); ok {
+				k, hv, found := strings.Cut(v, ":")
+				if !found {
+					r.appendErr(fmt.Errorf("e2http: invalid curl header %q", v))
+					continue
+				}
+				r.AddHeader(strings.TrimSpace(k), strings.TrimSpace(hv))
+			}
+		case "-d", "--data", "--data-raw", "--data-ascii", "--data-binary":
+			v, ok := needValue()
+			if !ok {
+				continue
+			}
+			if strings.HasPrefix(v, "@") && name != "--data-raw" {
+				b, err := os.ReadFile(v[1:])
+				if err != nil {
+					r.appendErr(err)
+					continue
+				}
+				v = string(b)
+				if name != "--data-binary" {
+					v = strings.NewReplacer("\r", "", "\n", "").Replace(v)
+				}
+			}
+			data = append(data, v)
+		case "-u", "--user":
+			if v, ok := needValue(); ok {
+				user, pass, _ := strings.Cut(v, ":")
+				r.SetBasicAuth(user, pass)
+			}
+		case "-x", "--proxy":
+			if v, ok := needValue(); ok {
+				r.Proxy(v)
+			}
+		case "-k", "--insecure":
+			r.InsecureSkipVerify(true)
+		case "-I", "--head":
+			method = http.MethodHead
+		case "--url":
+			if v, ok := needValue(); ok {
+				rawURL = v
+			}
+		default:
+			switch {
+			case slices.Contains(curlNoValueFlags, arg):
+			case len(arg) > 2 && arg[0] == '-' && arg[1] != '-':
+				// -sSL is -s -S -L, replace it in place and look at the single flags again
+				flags, ok := splitCurlShortFlags(arg)
+				if !ok {
+					r.appendErr(fmt.Errorf("e2http: unsupported curl option %s", arg))
+					continue
+				}
+				args = slices.Replace(args, i, i+1, flags...)
+				i--
+			case strings.HasPrefix(arg, "-"):
+				r.appendErr(fmt.Errorf("e2http: unsupported curl option %s", arg))
+			default:
+				rawURL = arg
+			}
+		}
+	}
+
+	if rawURL == "" {
+		r.appendErr(errors.New("e2http: curl command has no url"))
+		return r
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	r.URL(rawURL)
+
+	if len(data) > 0 {
+		if method == "" {
+			method = http.MethodPost
+		}
+		// like curl, every data option defaults to a form body
+		if r.reqHeaders.Get("Content-Type") == "" {
+			r.ContentType("application/x-www-form-urlencoded")
+		}
+		r.reqBody = bytes.NewBufferString(strings.Join(data, "&"))
+	}
+	if method != "" {
+		r.Method(method)
+	}
+	return r
+}
+
+// curlOption split "-XPOST" and "--request=POST" into the option name and its inline value
+func curlOption(arg string) (name, value string, inline bool) {
+	if strings.HasPrefix(arg, "--") {
+		if n, v, ok := strings.Cut(arg, "="); ok {
+			return n, v, true
+		}
+		return arg, "", false
+	}
+	if strings.HasPrefix(arg, "-") && len(arg) > 2 && strings.ContainsRune(curlValueShortFlags, rune(arg[1])) {
+		return arg[:2], arg[2:], true
+	}
+	return arg, "", false
+}
+
+// curlValueShortFlags are the single letter options taking a value
+const curlValueShortFlags = "XHdux"
+
+// splitCurlShortFlags split combined short flags, "-sSL" into "-s", "-S", "-L", a flag taking a
+// value ends the group and keeps the rest as its value, "-sXPOST" into "-s", "-XPOST"
+func splitCurlShortFlags(arg string) ([]string, bool) {
+	var flags []string
+	for j := 1; j < len(arg); j++ {
+		flag := "-" + arg[j:j+1]
+		switch {
+		case strings.ContainsRune(curlValueShortFlags, rune(arg[j])):
+			return append(flags, flag+arg[j+1:]), true
+		case flag == "-k" || flag == "-I" || slices.Contains(curlNoValueFlags, flag):
+			flags = append(flags, flag)
+		default:
+			return nil, false
+		}
+	}
+	return flags, true
+}
+
+// splitShellWords split a command line the way a POSIX shell would, supporting single quotes,
+// double quotes, $'...' escapes and backslash line continuations
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		cur     strings.Builder
+		inWord  bool
+		runes   = []rune(s)
+		errOpen = errors.New("e2http: unterminated quote in curl command")
+	)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case c == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] == '\n' {
+					continue
+				}
+				cur.WriteRune(runes[i])
+				inWord = true
+			}
+		case c == '\'':
+			end := slices.Index(runes[i+1:], '\'')
+			if end < 0 {
+				return nil, errOpen
+			}
+			cur.WriteString(string(runes[i+1 : i+1+end]))
+			i += end + 1
+			inWord = true
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			i += 2
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					closed = true
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						cur.WriteByte('\n')
+					case 'r':
+						cur.WriteByte('\r')
+					case 't':
+						cur.WriteByte('\t')
+					case 'x':
+						if i+2 < len(runes) {
+							if b, err := strconv.ParseUint(string(runes[i+1:i+3]), 16, 8); err == nil {
+								cur.WriteByte(byte(b))
+								i += 2
+								continue
+							}
+						}
+						cur.WriteRune('x')
+					default:
+						cur.WriteRune(runes[i])
+					}
+					continue
+				}
+				cur.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, errOpen
+			}
+			inWord = true
+		case c == '"':
+			i++
+			closed := false
+			for ; i < len(runes); i++ {
+				if runes[i] == '"' {
+					closed = true
+					break
+				}
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				cur.WriteRune(runes[i])
+			}
+			if !closed {
+				return nil, errOpen
+			}
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,105 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsCurl(t *testing.T) {
+	r := Builder(context.TODO()).
+		URL("https://example.com/api").
+		Query("q", "a b").
+		SetBasicAuth("user", "p'ss").
+		SetHeader("X-Trace", "1").
+		PostJSON(strings.NewReader(`{"name":"it's"}`)).
+		Proxy("http://127.0.0.1:3128").
+		InsecureSkipVerify(true)
+
+	cmd := r.AsCurl()
+	assert.Equal(t, `curl -u 'user:p'\''ss' -H 'Content-Type: application/json' -H 'X-Trace: 1' `+
+		`--data-binary '{"name":"it'\''s"}' -x http://127.0.0.1:3128 -k 'https://example.com/api?q=a+b'`, cmd)
+
+	// the body is still there for Do and the command parses back to the same request
+	assert.Equal(t, `{"name":"it's"}`, r.reqBody.(*bytes.Buffer).String())
+	parsed := FromCurl(context.TODO(), cmd)
+	assert.NoError(t, parsed.Err())
+	assert.Equal(t, cmd, parsed.AsCurl())
+
+	assert.Equal(t, `curl -X PUT --data-binary $'a\x00b\n' http://x`,
+		Builder(context.TODO()).URL("http://x").Method(http.MethodPut).PostRaw(strings.NewReader("a\x00b\n")).AsCurl())
+}
+
+func TestAsCurlCookiesAndMultipart(t *testing.T) {
+	u, _ := url.Parse("https://example.com/")
+	jar := NewCookieJar()
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+
+	r := Builder(context.TODO()).URL("https://example.com/upload").
+		AddCookie(&http.Cookie{Name: "lang", Value: "en"}).
+		CookieJar(jar).
+		PostMultipart(map[string]io.Reader{"note": bytes.NewReader([]byte("hello"))})
+	cmd := r.AsCurl()
+	assert.NoError(t, r.Err())
+	assert.Contains(t, cmd, `-H 'Cookie: lang=en; session=abc'`)
+	assert.Contains(t, cmd, `-F note=hello`)
+
+	// the value read for the command is still sent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, req.FormValue("note"))
+	}))
+	defer srv.Close()
+	r = Builder(context.TODO()).URL(srv.URL).PostMultipart(map[string]io.Reader{"note": bytes.NewReader([]byte("hello"))})
+	r.AsCurl()
+	assert.Equal(t, "hello", r.Do().BodyString())
+
+	r = Builder(context.TODO()).URL("https://example.com/upload").
+		PostMultipart(map[string]io.Reader{"note": iotest.ErrReader(errors.New("broken"))})
+	assert.Empty(t, r.AsCurl())
+	assert.Error(t, r.Err())
+}
+
+func TestFromCurl(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		user, pass, _ := req.BasicAuth()
+		_, _ = io.WriteString(w, strings.Join([]string{req.Method, req.Header.Get("Content-Type"), req.Header.Get("X-A"), user, pass, string(b)}, "|"))
+	}))
+	defer srv.Close()
+
+	r := FromCurl(context.TODO(), `curl -s -u bob:secret \
+  -H "X-A: \"quoted\"" -d a=1 --data 'b=2' `+srv.URL).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, `POST|application/x-www-form-urlencoded|"quoted"|bob|secret|a=1&b=2`, r.BodyString())
+
+	r = FromCurl(context.TODO(), `curl -XPATCH -H 'Content-Type: text/plain' --data-binary $'x\ty' `+srv.URL).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "PATCH|text/plain||||x\ty", r.BodyString())
+
+	r = FromCurl(context.TODO(), `curl -fsSL -sk -sXPUT -d a=1 `+srv.URL).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "PUT|application/x-www-form-urlencoded||||a=1", r.BodyString())
+	assert.True(t, r.tlsConfig().InsecureSkipVerify)
+
+	head := Builder(context.TODO()).URL(srv.URL).Method(http.MethodHead)
+	cmd := head.AsCurl()
+	assert.Equal(t, "curl -I "+srv.URL, cmd)
+	r = FromCurl(context.TODO(), cmd).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, http.MethodHead, r.method)
+	assert.Equal(t, http.MethodHead, FromCurl(context.TODO(), "curl -sI "+srv.URL).method)
+	assert.Equal(t, http.MethodHead, FromCurl(context.TODO(), "curl --head "+srv.URL).method)
+
+	assert.Error(t, FromCurl(context.TODO(), `curl --cert a.pem https://example.com`).Err())
+	assert.Error(t, FromCurl(context.TODO(), `curl -sE a.pem https://example.com`).Err())
+	assert.Error(t, FromCurl(context.TODO(), `curl -H 'unterminated`).Err())
+}
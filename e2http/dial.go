@@ -0,0 +1,295 @@
+package e2http
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Resolver look up the addresses of a host, *net.Resolver implements it
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// dialConfig build the DialContext of a transport. Through a proxy only the proxy host is dialed,
+// so resolve overrides apply to direct connections and to the proxy host, never to the target
+// host behind the proxy, which the proxy resolves itself
+type dialConfig struct {
+	timeout       time.Duration
+	keepAlive     time.Duration
+	fallbackDelay time.Duration           // Happy Eyeballs delay, negative disables the race
+	resolve       map[string][]netip.Addr // "host:port" or "host" to fixed addresses
+	resolver      Resolver                // nil means the dialer resolves by itself
+	unixSocket    string                  // every connection goes to this socket when set
+}
+
+func newDialConfig(timeout time.Duration) *dialConfig {
+	return &dialConfig{timeout: timeout, keepAlive: 30 * time.Second}
+}
+
+func (c *dialConfig) clone() *dialConfig {
+	cp := *c
+	cp.resolve = maps.Clone(c.resolve)
+	return &cp
+}
+
+// addResolve parse addrs for host, host may carry a port like curl --resolve example.com:443:127.0.0.1
+func (c *dialConfig) addResolve(host string, addrs ...string) error {
+	ips := make([]netip.Addr, 0, len(addrs))
+	for _, a := range addrs {
+		ip, err := netip.ParseAddr(a)
+		if err != nil {
+			return fmt.Errorf("e2http: resolve %s: %w", host, err)
+		}
+		ips = append(ips, ip)
+	}
+	if c.resolve == nil {
+		c.resolve = make(map[string][]netip.Addr)
+	}
+	c.resolve[host] = ips
+	return nil
+}
+
+func (c *dialConfig) netDialer() *net.Dialer {
+	return &net.Dialer{Timeout: c.timeout, KeepAlive: c.keepAlive, FallbackDelay: c.fallbackDelay}
+}
+
+func (c *dialConfig) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if c.unixSocket != "" {
+		return c.netDialer().DialContext(ctx, "unix", c.unixSocket)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, ok := c.resolve[net.JoinHostPort(host, port)]
+	if !ok {
+		ips, ok = c.resolve[host]
+	}
+	if !ok && c.resolver != nil {
+		if _, err := netip.ParseAddr(host); err != nil {
+			if ips, err = c.resolver.LookupNetIP(ctx, ipNetwork(network), host); err != nil {
+				return nil, err
+			}
+			ok = true
+		}
+	}
+	if !ok {
+		return c.netDialer().DialContext(ctx, network, addr)
+	}
+	if len(ips) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return c.dialAddrs(ctx, network, ips, port)
+}
+
+// dialAddrs dial the resolved addresses the way net.Dialer does: addresses of the first family are
+// tried in order, the other family joins the race after fallbackDelay or once the first one failed
+func (c *dialConfig) dialAddrs(ctx context.Context, network string, ips []netip.Addr, port string) (net.Conn, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	nd := &net.Dialer{KeepAlive: c.keepAlive}
+
+	var primaries, fallbacks []netip.Addr
+	for _, ip := range ips {
+		if ip.Is4() == ips[0].Is4() {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	if len(fallbacks) == 0 || c.fallbackDelay < 0 {
+		return dialSerial(ctx, nd, network, append(primaries, fallbacks...), port)
+	}
+	delay := c.fallbackDelay
+	if delay == 0 {
+		delay = 300 * time.Millisecond
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result, 2)
+	start := func(addrs []netip.Addr) {
+		go func() {
+			conn, err := dialSerial(raceCtx, nd, network, addrs, port)
+			results <- result{conn, err}
+		}()
+	}
+
+	start(primaries)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	started, done := 1, 0
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if started == 1 {
+				start(fallbacks)
+				started++
+			}
+		case res := <-results:
+			done++
+			if res.err == nil {
+				if done < started {
+					// the loser may still connect before it sees the cancellation
+					go func() {
+						if late := <-results; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}()
+				}
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if started == 1 {
+				start(fallbacks)
+				started++
+			} else if done == started {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+func dialSerial(ctx context.Context, nd *net.Dialer, network string, ips []netip.Addr, port string) (net.Conn, error) {
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := nd.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+func ipNetwork(network string) string {
+	switch network {
+	case "tcp4", "udp4":
+		return "ip4"
+	case "tcp6", "udp6":
+		return "ip6"
+	}
+	return "ip"
+}
+
+// CachingResolver remember lookups for a TTL, concurrent lookups of the same host share one query
+type CachingResolver struct {
+	resolver    Resolver
+	ttl         time.Duration
+	negativeTTL time.Duration
+	lock        sync.RWMutex
+	entries     map[string]resolverEntry
+	group       singleflight.Group
+}
+
+type resolverEntry struct {
+	ips     []netip.Addr
+	err     error
+	expires time.Time
+}
+
+// NewCachingResolver cache the answers of res for ttl, failures are cached for a tenth of it,
+// a nil res means net.DefaultResolver
+func NewCachingResolver(res Resolver, ttl time.Duration) *CachingResolver {
+	if res == nil {
+		res = net.DefaultResolver
+	}
+	return &CachingResolver{resolver: res, ttl: ttl, negativeTTL: ttl / 10, entries: make(map[string]resolverEntry)}
+}
+
+func (c *CachingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	key := network + "/" + host
+	c.lock.RLock()
+	e, ok := c.entries[key]
+	c.lock.RUnlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.ips, e.err
+	}
+
+	ch := c.group.DoChan(key, func() (any, error) {
+		ips, err := c.resolver.LookupNetIP(context.WithoutCancel(ctx), network, host)
+		ttl := c.ttl
+		if err != nil {
+			ttl = c.negativeTTL
+		}
+		c.lock.Lock()
+		c.entries[key] = resolverEntry{ips: ips, err: err, expires: time.Now().Add(ttl)}
+		c.lock.Unlock()
+		return ips, err
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]netip.Addr), nil
+	}
+}
+
+// Flush drop every cached answer
+func (c *CachingResolver) Flush() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clear(c.entries)
+}
+
+func (r *Context) dialConfig() *dialConfig {
+	if r.dial == nil {
+		r.dial = r.client.dial.clone()
+		r.ownTransport().DialContext = r.dial.DialContext
+	}
+	return r.dial
+}
+
+// Resolve pin host to addrs like curl --resolve, host may include the port, e.g. Resolve("example.com:443", "127.0.0.1"),
+// through a proxy it only affects the proxy host
+func (r *Context) Resolve(host string, addrs ...string) *Context {
+	if err := r.dialConfig().addResolve(host, addrs...); err != nil {
+		r.appendErr(err)
+	}
+	return r
+}
+
+// Resolver look hosts up with res, e.g. NewCachingResolver(nil, time.Minute)
+func (r *Context) Resolver(res Resolver) *Context {
+	r.dialConfig().resolver = res
+	return r
+}
+
+// FallbackDelay tune Happy Eyeballs (RFC 6555), the wait before racing the other address family, negative disables it
+func (r *Context) FallbackDelay(d time.Duration) *Context {
+	r.dialConfig().fallbackDelay = d
+	return r
+}
+
+// UnixSocket send every connection to the socket at path, the host of the URL is only used for
+// the Host header, e.g. UnixSocket("/var/run/docker.sock").URL("http://docker/version")
+func (r *Context) UnixSocket(path string) *Context {
+	r.dialConfig().unixSocket = path
+	return r
+}
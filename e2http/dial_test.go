@@ -0,0 +1,104 @@
+package e2http
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type countingResolver struct {
+	calls atomic.Int32
+	addrs []netip.Addr
+}
+
+func (c *countingResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	c.calls.Add(1)
+	return c.addrs, nil
+}
+
+func TestResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, req.Host)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	r := Builder(context.TODO()).URL("http://api.example.test:"+u.Port()).Resolve("api.example.test", "127.0.0.1").Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "api.example.test:"+u.Port(), r.BodyString())
+
+	// host:port takes precedence over host
+	r = Builder(context.TODO()).URL("http://api.example.test:"+u.Port()).
+		Resolve("api.example.test", "192.0.2.1").Resolve("api.example.test:"+u.Port(), "127.0.0.1").Do()
+	assert.NoError(t, r.Err())
+
+	r = Builder(context.TODO()).URL(srv.URL).Resolve("api.example.test", "not-an-ip").Do()
+	assert.Error(t, r.Err())
+
+	client := NewClient(DefaultClientOptions().WithResolve("api.example.test", "127.0.0.1"))
+	r = client.Request(context.TODO()).URL("http://api.example.test:" + u.Port()).Do()
+	assert.NoError(t, r.Err())
+}
+
+func TestCachingResolver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	// the IPv6 address is not listening, Happy Eyeballs falls back to IPv4 right after it fails
+	upstream := &countingResolver{addrs: []netip.Addr{netip.MustParseAddr("::1"), netip.MustParseAddr("127.0.0.1")}}
+	res := NewCachingResolver(upstream, time.Minute)
+	client := NewClient(DefaultClientOptions().WithResolver(res).WithFallbackDelay(10 * time.Millisecond))
+	for range 3 {
+		r := client.Request(context.TODO()).URL("http://cached.example.test:" + u.Port()).Do()
+		assert.NoError(t, r.Err())
+		client.CloseIdleConnections()
+	}
+	assert.EqualValues(t, 1, upstream.calls.Load())
+
+	res.Flush()
+	assert.NoError(t, client.Request(context.TODO()).URL("http://cached.example.test:"+u.Port()).Do().Err())
+	assert.EqualValues(t, 2, upstream.calls.Load())
+}
+
+func TestUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", sock)
+	assert.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, req.Host+req.URL.Path)
+	}), ReadHeaderTimeout: time.Second}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	r := Builder(context.TODO()).UnixSocket(sock).URL("http://docker/version").Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "docker/version", r.BodyString())
+}
+
+func TestResolveWithProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proxied = req.URL.String()
+		_, _ = io.WriteString(w, "via proxy")
+	}))
+	defer proxy.Close()
+	u, _ := url.Parse(proxy.URL)
+
+	// the proxy host goes through the same dialer
+	r := Builder(context.TODO()).URL("http://upstream.example.test/a").
+		Proxy("http://proxy.example.test:"+u.Port()).Resolve("proxy.example.test", "127.0.0.1").Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "via proxy", r.BodyString())
+	assert.Equal(t, "http://upstream.example.test/a", proxied)
+}
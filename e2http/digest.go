@@ -0,0 +1,219 @@
+package e2http
+
+import (
+	"crypto/md5" // #nosec G501 RFC 7616 keeps MD5 for legacy servers
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DigestAuth answer HTTP Digest challenges (RFC 7616), the first request goes out without
+// credentials, later requests reuse the challenge with an increasing nonce count
+type DigestAuth struct {
+	username string
+	password string
+	lock     sync.Mutex
+	ch       *digestChallenge
+	nc       int
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+}
+
+func NewDigestAuth(username, password string) *DigestAuth {
+	return &DigestAuth{username: username, password: password}
+}
+
+// digestAlgorithms supported by DigestAuth, digestPreference orders them strongest first
+var digestAlgorithms = map[string]func() hash.Hash{
+	"SHA-512-256": sha512.New512_256,
+	"SHA-256":     sha256.New,
+	"MD5":         md5.New,
+}
+
+var digestPreference = []string{"SHA-512-256", "SHA-256", "MD5"}
+
+var digestCnonce = func() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (d *DigestAuth) Authorize(req *http.Request) error {
+	d.lock.Lock()
+	ch := d.ch
+	d.nc++
+	nc := d.nc
+	d.lock.Unlock()
+	if ch == nil {
+		return nil
+	}
+
+	newHash := digestAlgorithms[strings.TrimSuffix(ch.algorithm, "-sess")]
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	cn := digestCnonce()
+	ncs := fmt.Sprintf("%08x", nc)
+	uri := req.URL.RequestURI()
+
+	ha1 := h(d.username + ":" + ch.realm + ":" + d.password)
+	if strings.HasSuffix(ch.algorithm, "-sess") {
+		ha1 = h(ha1 + ":" + ch.nonce + ":" + cn)
+	}
+	ha2 := h(req.Method + ":" + uri)
+	if ch.qop == "auth-int" {
+		body, err := peekRequestBody(req)
+		if err != nil {
+			return err
+		}
+		ha2 = h(req.Method + ":" + uri + ":" + h(string(body)))
+	}
+
+	var response string
+	if ch.qop == "" {
+		response = h(ha1 + ":" + ch.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + ch.nonce + ":" + ncs + ":" + cn + ":" + ch.qop + ":" + ha2)
+	}
+
+	username := d.username
+	if ch.userhash {
+		username = h(d.username + ":" + ch.realm)
+	}
+	parts := []string{
+		fmt.Sprintf(`username="%s"`, escapeQuotes(username)),
+		fmt.Sprintf(`realm="%s"`, escapeQuotes(ch.realm)),
+		fmt.Sprintf(`uri="%s"`, escapeQuotes(uri)),
+		"algorithm=" + ch.algorithm,
+		fmt.Sprintf(`nonce="%s"`, escapeQuotes(ch.nonce)),
+	}
+	if ch.qop != "" {
+		parts = append(parts, "nc="+ncs, fmt.Sprintf(`cnonce="%s"`, cn), "qop="+ch.qop)
+	}
+	parts = append(parts, fmt.Sprintf(`response="%s"`, response))
+	if ch.opaque != "" {
+		parts = append(parts, fmt.Sprintf(`opaque="%s"`, escapeQuotes(ch.opaque)))
+	}
+	if ch.userhash {
+		parts = append(parts, "userhash=true")
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(parts, ", "))
+	return nil
+}
+
+// Invalidate is not used, Digest credentials come from Challenge
+func (d *DigestAuth) Invalidate(*http.Request) bool {
+	return false
+}
+
+// Challenge pick the strongest supported Digest challenge of resp, a retry is only worth it
+// when req did not already answer the same nonce, unless the server flagged it stale
+func (d *DigestAuth) Challenge(req *http.Request, resp *http.Response) bool {
+	var best *digestChallenge
+	var stale bool
+	for _, v := range resp.Header.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		algorithm := strings.ToUpper(params["algorithm"])
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		base, sess := strings.CutSuffix(algorithm, "-SESS")
+		if _, ok := digestAlgorithms[base]; !ok || params["nonce"] == "" {
+			continue
+		}
+		ch := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: base,
+			userhash:  strings.EqualFold(params["userhash"], "true"),
+		}
+		if sess {
+			ch.algorithm += "-sess"
+		}
+		qops := strings.Split(params["qop"], ",")
+		for i := range qops {
+			qops[i] = strings.TrimSpace(qops[i])
+		}
+		switch {
+		case slices.Contains(qops, "auth"):
+			ch.qop = "auth"
+		case slices.Contains(qops, "auth-int"):
+			ch.qop = "auth-int"
+		}
+		if best == nil || digestRank(ch.algorithm) < digestRank(best.algorithm) {
+			best = ch
+			stale = strings.EqualFold(params["stale"], "true")
+		}
+	}
+	if best == nil {
+		return false
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	retry := stale || !strings.Contains(req.Header.Get("Authorization"), fmt.Sprintf(`nonce="%s"`, escapeQuotes(best.nonce)))
+	d.ch = best
+	d.nc = 0
+	return retry
+}
+
+func digestRank(algorithm string) int {
+	return slices.Index(digestPreference, strings.TrimSuffix(algorithm, "-sess"))
+}
+
+// parseAuthParams parse the comma separated key=value and key="quoted value" pairs of an auth header
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t,")
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[key] = value.String()
+	}
+	return params
+}
@@ -0,0 +1,217 @@
+package e2http
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ChecksumError is recorded when a downloaded file does not match the expected checksum,
+// the partial file is removed so the next attempt starts over
+type ChecksumError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("e2http: checksum mismatch for %s, expected %s, got %s", e.Path, e.Expected, e.Actual)
+}
+
+type download struct {
+	path     string
+	offset   int64
+	newHash  func() hash.Hash
+	checksum string
+}
+
+func (d *download) partPath() string {
+	return d.path + ".part"
+}
+
+// validatorPath keep the ETag or Last-Modified the part file was downloaded with, sent as If-Range
+func (d *download) validatorPath() string {
+	return d.path + ".part.validator"
+}
+
+// DownloadToFile stream the response body into path, the data is kept in path+".part" until it
+// is complete and verified. An existing part file is resumed with a Range request guarded by
+// If-Range, so it is only continued while the server reports the same strong ETag or Last-Modified,
+// a part file without a validator starts over
+func (r *Context) DownloadToFile(path string) *Context {
+	r.streaming = true
+	if r.download == nil {
+		r.download = &download{}
+	}
+	r.download.path = path
+	return r
+}
+
+// Checksum verify the file written by DownloadToFile, sum is hex encoded, e.g. Checksum(sha256.New, "ab12...")
+func (r *Context) Checksum(newHash func() hash.Hash, sum string) *Context {
+	if r.download == nil {
+		r.download = &download{}
+	}
+	r.download.newHash = newHash
+	r.download.checksum = strings.ToLower(strings.TrimSpace(sum))
+	return r
+}
+
+// prepare ask for the missing range when a part file and its validator exist, a changed
+// resource makes the server answer 200 with the whole body
+func (d *download) prepare(req *http.Request) {
+	d.offset = 0
+	fi, err := os.Stat(d.partPath())
+	if err != nil || fi.Size() == 0 {
+		return
+	}
+	validator, err := os.ReadFile(d.validatorPath())
+	if err != nil || len(bytes.TrimSpace(validator)) == 0 {
+		return
+	}
+	d.offset = fi.Size()
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+	req.Header.Set("If-Range", string(bytes.TrimSpace(validator)))
+}
+
+// saveValidator keep the strong ETag, or else the Last-Modified, of a response starting the part file
+func (d *download) saveValidator(resp *http.Response) error {
+	validator := resp.Header.Get("ETag")
+	if strings.HasPrefix(validator, "W/") {
+		// If-Range only accepts strong validators
+		validator = ""
+	}
+	if validator == "" {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		err := os.Remove(d.validatorPath())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.WriteFile(d.validatorPath(), []byte(validator), 0644) // #nosec G306
+}
+
+// accepted report whether a 416 means the part file is already complete
+func (d *download) accepted(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable || d.offset == 0 {
+		return false
+	}
+	_, _, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	return ok && total == d.offset
+}
+
+// write the body into the part file, req is the request resp answers
+func (d *download) write(req *http.Request, resp *http.Response, body io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return err
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case d.accepted(resp):
+		return d.finish()
+	case resp.StatusCode == http.StatusPartialContent:
+		start, _, _, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != d.offset {
+			return fmt.Errorf("e2http: unexpected Content-Range %q, want start %d", resp.Header.Get("Content-Range"), d.offset)
+		}
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// a fresh download, or the resource changed since the part file was written
+		d.offset = 0
+		flags |= os.O_TRUNC
+		if err := d.saveValidator(resp); err != nil {
+			return err
+		}
+	default:
+		snippet, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySnippet))
+		return newHTTPError(req, resp, snippet)
+	}
+
+	f, err := os.OpenFile(d.partPath(), flags, 0644) // #nosec G302 G304
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return d.finish()
+}
+
+// finish verify the part file and move it into place
+func (d *download) finish() error {
+	if d.newHash != nil && d.checksum != "" {
+		f, err := os.Open(d.partPath())
+		if err != nil {
+			return err
+		}
+		h := d.newHash()
+		_, err = io.Copy(h, f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		if actual := hex.EncodeToString(h.Sum(nil)); actual != d.checksum {
+			_ = os.Remove(d.partPath())
+			_ = os.Remove(d.validatorPath())
+			return &ChecksumError{Path: d.path, Expected: d.checksum, Actual: actual}
+		}
+	}
+	if err := os.Rename(d.partPath(), d.path); err != nil {
+		return err
+	}
+	_ = os.Remove(d.validatorPath())
+	return nil
+}
+
+// parseContentRange parse "bytes 0-99/1000" and "bytes */1000", total is -1 when unknown
+func parseContentRange(v string) (start, end, total int64, ok bool) {
+	unit, rest, found := strings.Cut(strings.TrimSpace(v), " ")
+	if !found || unit != "bytes" {
+		return 0, 0, 0, false
+	}
+	rng, size, found := strings.Cut(rest, "/")
+	if !found {
+		return 0, 0, 0, false
+	}
+	total = -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		total = n
+	}
+	if rng == "*" {
+		return 0, 0, total, true
+	}
+	s, e, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, 0, false
+	}
+	var err1, err2 error
+	start, err1 = strconv.ParseInt(s, 10, 64)
+	end, err2 = strconv.ParseInt(e, 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, 0, false
+	}
+	return start, end, total, true
+}
@@ -0,0 +1,123 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadToFile(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(content)
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ranges = append(ranges, req.Header.Get("Range"))
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, req, "data.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "data.bin")
+	assert.NoError(t, os.WriteFile(path+".part", content[:4000], 0644))
+	assert.NoError(t, os.WriteFile(path+".part.validator", []byte(`"v1"`), 0644))
+
+	var last Progress
+	r := Builder(context.TODO()).
+		URL(srv.URL).
+		ErrorOnNon2xx().
+		DownloadProgress(func(p Progress) { last = p }).
+		Checksum(sha256.New, hex.EncodeToString(sum[:])).
+		DownloadToFile(path).
+		Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, []string{"bytes=4000-"}, ranges)
+	assert.Equal(t, Progress{Done: int64(len(content)), Total: int64(len(content)), Speed: last.Speed}, last)
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, b)
+	assert.NoFileExists(t, path+".part")
+	assert.NoFileExists(t, path+".part.validator")
+
+	r = Builder(context.TODO()).
+		URL(srv.URL).
+		Checksum(sha256.New, "00").
+		DownloadToFile(path).
+		Do()
+	var ce *ChecksumError
+	assert.True(t, errors.As(r.Err(), &ce))
+	assert.NoFileExists(t, path+".part")
+}
+
+func TestDownloadResumeChanged(t *testing.T) {
+	v1 := bytes.Repeat([]byte("a"), 10000)
+	v2 := bytes.Repeat([]byte("b"), 12000)
+	var (
+		version  = 1
+		ifRanges []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ifRanges = append(ifRanges, req.Header.Get("If-Range"))
+		if version == 1 {
+			// the first attempt breaks off in the middle of the body
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Content-Length", strconv.Itoa(len(v1)))
+			_, _ = w.Write(v1[:4000])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Set("ETag", `"v2"`)
+		http.ServeContent(w, req, "data.bin", time.Time{}, bytes.NewReader(v2))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "data.bin")
+	r := Builder(context.TODO()).URL(srv.URL).DownloadToFile(path).Do()
+	assert.Error(t, r.Err())
+	part, _ := os.ReadFile(path + ".part")
+	assert.Equal(t, v1[:4000], part)
+
+	version = 2
+	r = Builder(context.TODO()).URL(srv.URL).DownloadToFile(path).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, []string{"", `"v1"`}, ifRanges)
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, v2, b)
+	assert.NoFileExists(t, path+".part")
+	assert.NoFileExists(t, path+".part.validator")
+}
+
+func TestDownloadMiddlewareResponse(t *testing.T) {
+	// a response made up by a middleware carries no Request
+	stub := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				Status:     "404 Not Found",
+				StatusCode: http.StatusNotFound,
+				Header:     make(http.Header),
+				Body:       io.NopCloser(strings.NewReader("missing")),
+			}, nil
+		}
+	}
+	path := filepath.Join(t.TempDir(), "data.bin")
+	r := Builder(context.TODO()).URL("http://example.test/data.bin").Use(stub).DownloadToFile(path).Do()
+	var he *HTTPError
+	assert.True(t, errors.As(r.Err(), &he))
+	assert.Equal(t, http.StatusNotFound, he.StatusCode)
+	assert.Equal(t, "http://example.test/data.bin", he.URL)
+	assert.NoFileExists(t, path)
+}
@@ -0,0 +1,102 @@
+package e2http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// maxErrorBodySnippet how many bytes of the response body HTTPError keeps
+const maxErrorBodySnippet = 512
+
+// HTTPError is recorded when the response status is not accepted by ExpectStatus or ErrorOnNon2xx
+type HTTPError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	Body       []byte // truncated to the first maxErrorBodySnippet bytes
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("e2http: %s %s: unexpected status %s", e.Method, e.URL, e.Status)
+	if snippet := strings.TrimSpace(string(e.Body)); snippet != "" {
+		msg += ": " + snippet
+	}
+	return msg
+}
+
+// ExpectStatus treat any response status other than codes as an error
+func (r *Context) ExpectStatus(codes ...int) *Context {
+	r.expectStatus = append(r.expectStatus, codes...)
+	return r
+}
+
+// ErrorOnNon2xx treat any response status outside 200-299 as an error
+func (r *Context) ErrorOnNon2xx() *Context {
+	r.errorOnNon2xx = true
+	return r
+}
+
+func (r *Context) statusAccepted(code int) bool {
+	if len(r.expectStatus) > 0 {
+		return slices.Contains(r.expectStatus, code)
+	}
+	if r.errorOnNon2xx {
+		return code >= 200 && code < 300
+	}
+	return true
+}
+
+// statusError build an HTTPError for an unaccepted status, the body is still buffered
+// into Body() unless streaming, in which case only the snippet is read. A failure reading the
+// body, e.g. BodyTooLargeError, is joined to the HTTPError and Body() stays empty as in readBody
+func (r *Context) statusError(resp *http.Response) error {
+	if r.statusAccepted(resp.StatusCode) || (r.download != nil && r.download.accepted(resp)) {
+		return nil
+	}
+
+	var (
+		body    []byte
+		readErr error
+	)
+	if r.streaming {
+		body, readErr = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySnippet))
+	} else {
+		body, readErr = io.ReadAll(r.limitBody(resp))
+		if readErr == nil {
+			r.respBody = body
+		}
+	}
+	req := r.responseRequest(resp)
+	if readErr != nil {
+		return errors.Join(newHTTPError(req, resp, body), readErr)
+	}
+	return newHTTPError(req, resp, body)
+}
+
+// responseRequest is the request resp answers, a response made up by a middleware may have none
+func (r *Context) responseRequest(resp *http.Response) *http.Request {
+	if resp.Request != nil {
+		return resp.Request
+	}
+	return r.req
+}
+
+func newHTTPError(req *http.Request, resp *http.Response, body []byte) *HTTPError {
+	if len(body) > maxErrorBodySnippet {
+		body = body[:maxErrorBodySnippet]
+	}
+	return &HTTPError{
+		Method:     req.Method,
+		URL:        req.URL.Redacted(),
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header.Clone(),
+		Body:       slices.Clone(body),
+	}
+}
@@ -0,0 +1,490 @@
+package e2http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAREnv is the environment variable selecting the mode of HARFromEnv: record, replay or off
+const HAREnv = "E2HTTP_HAR_MODE"
+
+type HARMode string
+
+const (
+	HAROff    HARMode = "off"    // requests go to the network untouched
+	HARRecord HARMode = "record" // requests go to the network, the exchanges are kept for Save
+	HARReplay HARMode = "replay" // requests are answered from the HAR file, nothing leaves the process
+)
+
+// HARNoMatchError is returned in replay mode when the HAR file holds no entry for a request
+type HARNoMatchError struct {
+	Method string
+	URL    string
+}
+
+func (e *HARNoMatchError) Error() string {
+	return fmt.Sprintf("e2http: no HAR entry for %s %s", e.Method, e.URL)
+}
+
+// HAR is the root of a HAR 1.2 document, http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"` // milliseconds
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARPostData carry the request body, binary bodies are base64 encoded and flagged by the custom _encoding field
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"_encoding,omitempty"`
+}
+
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type HARTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HARRecorder record the exchanges of the requests using its Middleware into a HAR file,
+// or answer them from that file in replay mode, e.g.
+//
+//	rec := e2http.HARFromEnv("testdata/api.har")
+//	e2http.UseDefault(rec.Middleware())
+//	defer rec.Save()
+type HARRecorder struct {
+	path string
+	mode HARMode
+	lock sync.Mutex
+	har  HAR
+	used []bool
+	err  error
+}
+
+// NewHARRecorder create a recorder for path, in replay mode the file is loaded immediately
+func NewHARRecorder(path string, mode HARMode) *HARRecorder {
+	h := &HARRecorder{
+		path: path,
+		mode: mode,
+		har: HAR{Log: HARLog{
+			Version: "1.2",
+			Creator: HARCreator{Name: "e2http", Version: "1.0"},
+		}},
+	}
+	if mode == HARReplay {
+		h.err = h.load()
+	}
+	return h
+}
+
+// HARFromEnv create a recorder for path whose mode comes from E2HTTP_HAR_MODE, unset means off
+func HARFromEnv(path string) *HARRecorder {
+	mode := HARMode(strings.ToLower(strings.TrimSpace(os.Getenv(HAREnv))))
+	switch mode {
+	case HARRecord, HARReplay:
+	default:
+		mode = HAROff
+	}
+	return NewHARRecorder(path, mode)
+}
+
+// HAR record or replay this request with rec
+func (r *Context) HAR(rec *HARRecorder) *Context {
+	return r.Use(rec.Middleware())
+}
+
+func (h *HARRecorder) Mode() HARMode {
+	return h.mode
+}
+
+// Entries return a copy of the recorded or loaded entries
+func (h *HARRecorder) Entries() []*HAREntry {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return append([]*HAREntry(nil), h.har.Log.Entries...)
+}
+
+func (h *HARRecorder) load() error {
+	b, err := os.ReadFile(h.path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &h.har); err != nil {
+		return fmt.Errorf("e2http: parse HAR %s: %w", h.path, err)
+	}
+	h.used = make([]bool, len(h.har.Log.Entries))
+	return nil
+}
+
+// Save write the recorded entries to the file, it does nothing unless the recorder is in record mode.
+// An exchange is recorded once its response body was read to the end or closed
+func (h *HARRecorder) Save() error {
+	if h.mode != HARRecord {
+		return nil
+	}
+	h.lock.Lock()
+	b, err := json.MarshalIndent(h.har, "", "  ")
+	h.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(h.path, b, 0644) // #nosec G306
+}
+
+func (h *HARRecorder) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		switch h.mode {
+		case HARRecord:
+			return h.record(next)
+		case HARReplay:
+			return h.replay
+		default:
+			return next
+		}
+	}
+}
+
+func (h *HARRecorder) record(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		reqBody, err := peekRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, err := next(req)
+		if err != nil {
+			return resp, err
+		}
+		wait := time.Since(start)
+		entry := &HAREntry{StartedDateTime: start, Request: harRequest(req, reqBody)}
+		// the body is copied while the caller reads it, so streams like SSE are recorded as they go,
+		// the entry is added once the body hits EOF or is closed
+		finish := func(body []byte) {
+			elapsed := time.Since(start)
+			entry.Time = float64(elapsed) / float64(time.Millisecond)
+			entry.Response = harResponse(resp, body)
+			entry.Timings = HARTimings{
+				Wait:    float64(wait) / float64(time.Millisecond),
+				Receive: float64(elapsed-wait) / float64(time.Millisecond),
+			}
+			h.lock.Lock()
+			h.har.Log.Entries = append(h.har.Log.Entries, entry)
+			h.lock.Unlock()
+		}
+		if resp.Body == nil {
+			finish(nil)
+			return resp, nil
+		}
+		resp.Body = &harBody{rc: resp.Body, finish: finish}
+		return resp, nil
+	}
+}
+
+// harBody tee the response body into the HAR entry
+type harBody struct {
+	rc     io.ReadCloser
+	buf    bytes.Buffer
+	once   sync.Once
+	finish func(body []byte)
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.rc.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
+
+// Close record what was read so far, a stream closed early keeps its partial body
+func (b *harBody) Close() error {
+	err := b.rc.Close()
+	b.done()
+	return err
+}
+
+func (b *harBody) done() {
+	b.once.Do(func() { b.finish(b.buf.Bytes()) })
+}
+
+// replay answer req with the first unused entry matching method, URL and body,
+// once all matching entries are used the last one keeps answering
+func (h *HARRecorder) replay(req *http.Request) (*http.Response, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	reqBody, err := peekRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	h.lock.Lock()
+	found := -1
+	for i, e := range h.har.Log.Entries {
+		if !e.Request.matches(req, reqBody) {
+			continue
+		}
+		found = i
+		if !h.used[i] {
+			break
+		}
+	}
+	if found >= 0 {
+		h.used[found] = true
+	}
+	h.lock.Unlock()
+
+	if found < 0 {
+		return nil, &HARNoMatchError{Method: req.Method, URL: req.URL.Redacted()}
+	}
+	return h.har.Log.Entries[found].Response.response(req)
+}
+
+// peekRequestBody read the request body and put a fresh copy back
+func peekRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(b))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(b)), nil }
+	return b, nil
+}
+
+func harRequest(req *http.Request, body []byte) HARRequest {
+	hr := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(req.Header, harRedactedHeaders...),
+		QueryString: []HARNameValue{},
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	if hr.HTTPVersion == "" {
+		hr.HTTPVersion = "HTTP/1.1"
+	}
+	for _, c := range req.Cookies() {
+		hr.Cookies = append(hr.Cookies, HARNameValue{Name: c.Name, Value: harRedacted})
+	}
+	for k, vs := range req.URL.Query() {
+		for _, v := range vs {
+			hr.QueryString = append(hr.QueryString, HARNameValue{Name: k, Value: v})
+		}
+	}
+	if body != nil {
+		hr.PostData = &HARPostData{MimeType: req.Header.Get("Content-Type")}
+		hr.PostData.Text, hr.PostData.Encoding = harText(body)
+	}
+	return hr
+}
+
+func harResponse(resp *http.Response, body []byte) HARResponse {
+	hr := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(resp.Header, harRedactedHeaders...),
+		Content: HARContent{
+			Size:     int64(len(body)),
+			MimeType: resp.Header.Get("Content-Type"),
+		},
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+	for _, c := range resp.Cookies() {
+		hr.Cookies = append(hr.Cookies, HARNameValue{Name: c.Name, Value: harRedacted})
+	}
+	hr.Content.Text, hr.Content.Encoding = harText(body)
+	return hr
+}
+
+// harRedactedHeaders keep credentials and session cookies out of HAR files which usually end up
+// in version control, cookie values are redacted the same way and only their names are kept
+var harRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+const harRedacted = "REDACTED"
+
+func harHeaders(h http.Header, redact ...string) []HARNameValue {
+	out := []HARNameValue{}
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		for _, v := range h[k] {
+			if slices.Contains(redact, k) {
+				v = harRedacted
+			}
+			out = append(out, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return out
+}
+
+// harText keep readable bodies as text and base64 encode the rest
+func harText(b []byte) (text, encoding string) {
+	if utf8.Valid(b) {
+		return string(b), ""
+	}
+	return base64.StdEncoding.EncodeToString(b), "base64"
+}
+
+func harBytes(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+func (hr *HARRequest) matches(req *http.Request, body []byte) bool {
+	if hr.Method != req.Method || !sameURL(hr.URL, req.URL.String()) {
+		return false
+	}
+	var recorded []byte
+	if hr.PostData != nil {
+		b, err := harBytes(hr.PostData.Text, hr.PostData.Encoding)
+		if err != nil {
+			return false
+		}
+		recorded = b
+	}
+	return bytes.Equal(recorded, body) || sameJSON(recorded, body, req.Header.Get("Content-Type"))
+}
+
+// sameURL compare two URLs ignoring the order of the query parameters
+func sameURL(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ua, err1 := url.Parse(a)
+	ub, err2 := url.Parse(b)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	ua.RawQuery = ua.Query().Encode()
+	ub.RawQuery = ub.Query().Encode()
+	return ua.String() == ub.String()
+}
+
+// sameJSON treat JSON bodies with the same content but different formatting as equal
+func sameJSON(a, b []byte, contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	if mt != "application/json" && !strings.HasSuffix(mt, "+json") {
+		return false
+	}
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
+
+func (hr *HARResponse) response(req *http.Request) (*http.Response, error) {
+	body, err := harBytes(hr.Content.Text, hr.Content.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	header := make(http.Header)
+	for _, h := range hr.Headers {
+		header.Add(h.Name, h.Value)
+	}
+	// the recorded body is already decoded
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+
+	proto := hr.HTTPVersion
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	if !ok {
+		proto, major, minor = "HTTP/1.1", 1, 1
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", hr.Status, hr.StatusText),
+		StatusCode:    hr.Status,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
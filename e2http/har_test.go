@@ -0,0 +1,96 @@
+package e2http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHARRecordReplay(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		b, _ := io.ReadAll(req.Body)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, req.Method+" "+req.URL.RawQuery+" "+string(b))
+	}))
+	url := srv.URL
+
+	path := filepath.Join(t.TempDir(), "api.har")
+	rec := NewHARRecorder(path, HARRecord)
+	r := Builder(context.TODO()).URL(url).Query("a", "1").SetBearerAuth("secret").HAR(rec).Method(http.MethodPost).PostRaw(strings.NewReader("hello")).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "POST a=1 hello", r.BodyString())
+	assert.NoError(t, rec.Save())
+	assert.Len(t, rec.Entries(), 1)
+	assert.Contains(t, rec.Entries()[0].Request.Headers, HARNameValue{Name: "Authorization", Value: "REDACTED"})
+	srv.Close()
+
+	t.Setenv(HAREnv, "replay")
+	rec = HARFromEnv(path)
+	assert.Equal(t, HARReplay, rec.Mode())
+	r = Builder(context.TODO()).URL(url).Query("a", "1").HAR(rec).Method(http.MethodPost).PostRaw(strings.NewReader("hello")).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, http.StatusOK, r.StatusCode())
+	assert.Equal(t, "POST a=1 hello", r.BodyString())
+	assert.Equal(t, "text/plain", r.Headers().Get("Content-Type"))
+	assert.Equal(t, 1, hits)
+
+	r = Builder(context.TODO()).URL(url).HAR(rec).Method(http.MethodPost).PostRaw(strings.NewReader("other")).Do()
+	var nm *HARNoMatchError
+	assert.True(t, errors.As(r.Err(), &nm))
+}
+
+func TestHARRecordRedactCookies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "server-secret"})
+	}))
+	defer srv.Close()
+
+	rec := NewHARRecorder(filepath.Join(t.TempDir(), "api.har"), HARRecord)
+	r := Builder(context.TODO()).URL(srv.URL).SetHeader("Cookie", "session=client-secret").HAR(rec).Do()
+	assert.NoError(t, r.Err())
+	assert.NoError(t, rec.Save())
+
+	b, err := os.ReadFile(rec.path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "secret")
+	e := rec.Entries()[0]
+	assert.Equal(t, []HARNameValue{{Name: "session", Value: "REDACTED"}}, e.Request.Cookies)
+	assert.Equal(t, []HARNameValue{{Name: "session", Value: "REDACTED"}}, e.Response.Cookies)
+	assert.Contains(t, e.Response.Headers, HARNameValue{Name: "Set-Cookie", Value: "REDACTED"})
+}
+
+func TestHARRecordStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, "data: one\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	rec := NewHARRecorder(filepath.Join(t.TempDir(), "sse.har"), HARRecord)
+	stop := errors.New("stop")
+	var events []string
+	r := Builder(ctx).URL(srv.URL).HAR(rec).SSE(func(e Event) error {
+		events = append(events, e.Data)
+		return stop
+	}).Do()
+	assert.ErrorIs(t, r.Err(), stop)
+	assert.NoError(t, ctx.Err())
+	assert.Equal(t, []string{"one"}, events)
+	assert.Len(t, rec.Entries(), 1)
+	assert.Equal(t, "data: one\n\n", rec.Entries()[0].Response.Content.Text)
+}
@@ -4,14 +4,12 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"io"
 	"maps"
-	"mime/multipart"
-	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"os"
 	"slices"
 	"strings"
 	"time"
@@ -21,10 +19,14 @@ import (
 )
 
 type Context struct {
+	client         *Client
 	cli            *http.Client
 	ctx            context.Context
 	connectTimeout time.Duration // connectTimeout, readWriteTimeout
 	url            *url.URL
+	baseURL        *url.URL
+	path           string
+	query          url.Values
 	method         string
 	reqHeaders     http.Header
 	transport      *http.Transport
@@ -35,26 +37,46 @@ type Context struct {
 	respBody    []byte
 	respHeaders http.Header
 	respCookies []*http.Cookie
-
-	delHeaders     []string
-	respStatusCode int
-	errs           []error
-	toJsonPointer  any
-	outWriter      io.Writer
-	dumpReqWriter  io.Writer
-	dumpReqBody    bool
-	dumpRespWriter io.Writer
-	dumpRespBody   bool
+	reqCookies  []*http.Cookie
+
+	delHeaders       []string
+	respStatusCode   int
+	errs             []error
+	toJsonPointer    any
+	outWriter        io.Writer
+	dumpReqWriter    io.Writer
+	dumpReqBody      bool
+	dumpRespWriter   io.Writer
+	dumpRespBody     bool
+	retry            *RetryPolicy
+	attempts         int
+	streaming        bool
+	streamFn         func(rd io.Reader) error
+	maxBodySize      int64
+	expectStatus     []int
+	errorOnNon2xx    bool
+	middlewares      []Middleware
+	fromCache        bool
+	uploadProgress   ProgressFunc
+	downloadProgress ProgressFunc
+	download         *download
+	sse              *sseState
+	decodePointer    any
+	proxyURL         *url.URL
+	dial             *dialConfig
+}
+
+type contextKey struct{}
+
+// contextFromRequest return the Context which built req, middlewares use it to report back
+func contextFromRequest(req *http.Request) *Context {
+	r, _ := req.Context().Value(contextKey{}).(*Context)
+	return r
 }
 
+// Builder start a request on the shared default client
 func Builder(ctx context.Context) *Context {
-	r := &Context{
-		cli:        &http.Client{},
-		ctx:        ctx,
-		method:     http.MethodGet,
-		reqHeaders: make(map[string][]string),
-	}
-	return r
+	return defaultClient.Request(ctx)
 }
 
 func (r *Context) URL(u string) *Context {
@@ -69,25 +91,20 @@ func (r *Context) URL(u string) *Context {
 func (r *Context) ConnectTimeout(timeout time.Duration) *Context {
 	r.connectTimeout = timeout
 	if r.connectTimeout > 0 {
-		ct := func(ctx context.Context, network, addr string) (net.Conn, error) {
-			conn, err := net.DialTimeout(network, addr, r.connectTimeout)
-			if err != nil {
-				return nil, err
-			}
-			return conn, nil
-		}
-
-		if r.transport == nil {
-			r.transport = &http.Transport{
-				DialContext: ct,
-			}
-		} else {
-			r.transport.DialContext = ct
-		}
+		r.dialConfig().timeout = r.connectTimeout
 	}
 	return r
 }
 
+// ownTransport return a transport private to this request, cloned from the client one,
+// it is closed once Do returns so one-off settings do not leak connections
+func (r *Context) ownTransport() *http.Transport {
+	if r.transport == nil {
+		r.transport = r.client.transport.Clone()
+	}
+	return r.transport
+}
+
 func basicAuth(username, password string) string {
 	auth := username + ":" + password
 	return base64.StdEncoding.EncodeToString([]byte(auth))
@@ -147,14 +164,6 @@ func parseBasicAuth(auth string) (string, string, bool) {
 	return username, password, true
 }
 
-func (r *Context) SetCookies(c []*http.Cookie) *Context {
-	if r.respCookies == nil {
-		r.respCookies = make([]*http.Cookie, len(c))
-	}
-	r.respCookies = slices.Clone(c)
-	return r
-}
-
 // Proxy set proxy, e.g. socks5://127.0.0.1:1080, http://127.0.0.1:3128
 func (r *Context) Proxy(p string) *Context {
 	proxyUrl, err := url.Parse(p)
@@ -162,13 +171,8 @@ func (r *Context) Proxy(p string) *Context {
 		r.appendErr(err)
 		return r
 	}
-	if r.transport == nil {
-		r.transport = &http.Transport{
-			Proxy: http.ProxyURL(proxyUrl),
-		}
-	} else {
-		r.transport.Proxy = http.ProxyURL(proxyUrl)
-	}
+	r.proxyURL = proxyUrl
+	r.ownTransport().Proxy = http.ProxyURL(proxyUrl)
 	return r
 }
 
@@ -224,50 +228,6 @@ func (r *Context) PostJSON(rd io.Reader) *Context {
 	return r
 }
 
-func (r *Context) PostMultipart(values map[string]io.Reader) *Context {
-	return r.postMultipart(http.MethodPost, values)
-}
-
-func (r *Context) PutMultipart(values map[string]io.Reader) *Context {
-	return r.postMultipart(http.MethodPut, values)
-}
-
-func (r *Context) postMultipart(method string, values map[string]io.Reader) *Context {
-	r.Method(method)
-	var buf bytes.Buffer
-	w := multipart.NewWriter(&buf)
-	cloz := func(x io.Reader) {
-		if rd, ok := x.(io.Closer); ok && rd != nil {
-			_ = rd.Close()
-		}
-	}
-	for key, rd := range values {
-		var fw io.Writer
-		var err error
-		if x, ok := rd.(*os.File); ok {
-			if fw, err = w.CreateFormFile(key, x.Name()); err != nil {
-				cloz(rd)
-				break
-			}
-		} else {
-			if fw, err = w.CreateFormField(key); err != nil {
-				cloz(rd)
-				break
-			}
-		}
-		if _, err = io.Copy(fw, rd); err != nil {
-			cloz(rd)
-			break
-		}
-		cloz(rd)
-	}
-
-	_ = w.Close()
-	r.ContentType(w.FormDataContentType())
-	r.reqBody = &buf
-	return r
-}
-
 func (r *Context) SetHeaders(h map[string]string) *Context {
 	for k, v := range h {
 		r.reqHeaders.Set(k, v)
@@ -325,24 +285,65 @@ func (r *Context) Write(w io.Writer) *Context {
 }
 
 func (r *Context) Do() *Context {
+	if r.sse != nil {
+		return r.doSSE()
+	}
+	return r.do()
+}
+
+func (r *Context) do() *Context {
+	// a request whose building failed, e.g. a bad pin, CA or Resolve override, is never sent
+	if len(r.errs) > 0 {
+		return r
+	}
+	r.cli.Transport = r.roundTripper()
 	if r.transport != nil {
-		r.cli.Transport = r.transport
+		defer r.transport.CloseIdleConnections()
 	}
 
-	if req, err := http.NewRequestWithContext(r.ctx, r.method, r.url.String(), r.reqBody); err == nil {
-		r.req = req
-	} else {
+	u, err := r.requestURL()
+	if err != nil {
 		r.appendErr(err)
 		return r
 	}
 
-	r.req.Header = r.reqHeaders
+	var body func() (io.Reader, int64)
+	if r.reqBody != nil {
+		if r.retry != nil {
+			b, err := replayBody(r.reqBody)
+			if err != nil {
+				r.appendErr(err)
+				return r
+			}
+			body = b
+			defer closeReader(r.reqBody)
+		} else {
+			body = func() (io.Reader, int64) { return r.reqBody, -1 }
+		}
+	}
 
-	for _, k := range r.delHeaders {
-		r.req.Header.Del(k)
+	var resp *http.Response
+	for r.attempts = 1; ; r.attempts++ {
+		if err = r.newRequest(u, body); err != nil {
+			r.appendErr(err)
+			return r
+		}
+
+		resp, err = r.cli.Do(r.req)
+		if r.retry == nil || !r.retry.shouldRetry(r.attempts, resp, err) {
+			break
+		}
+
+		wait := r.retry.backoff(r.attempts, resp)
+		if resp != nil {
+			drainBody(resp.Body)
+		}
+		if err := sleepContext(r.ctx, wait); err != nil {
+			r.appendErr(err)
+			return r
+		}
 	}
 
-	resp, err := r.cli.Do(r.req)
 	if err != nil {
 		r.appendErr(err)
 		return r
@@ -363,55 +364,122 @@ func (r *Context) Do() *Context {
 	}
 	r.respCookies = slices.Clone(resp.Cookies())
 
-	if b, err := io.ReadAll(resp.Body); err == nil {
-		r.respBody = b
-	} else {
+	if err := r.statusError(resp); err != nil {
+		r.appendErr(err)
+	} else if err := r.readBody(resp); err != nil {
 		r.appendErr(err)
 		return r
 	}
 
-	if r.toJsonPointer != nil {
-		if err := e2json.MustFromJSONByte(r.respBody, r.toJsonPointer); err != nil {
+	if r.dumpRespWriter != nil {
+		if b, err := httputil.DumpResponse(resp, false); err == nil {
+			if _, err := io.Copy(r.dumpRespWriter, bytes.NewReader(b)); err != nil {
+				r.appendErr(err)
+				return r
+			}
+			if r.dumpRespBody && !r.streaming {
+				e2exec.SilentError(r.dumpRespWriter.Write(r.respBody))
+			}
+		} else {
 			r.appendErr(err)
 			return r
 		}
 	}
 
+	return r
+}
+
+func (r *Context) readBody(resp *http.Response) error {
+	body := r.limitBody(resp)
+	if r.downloadProgress != nil {
+		var offset int64
+		total := resp.ContentLength
+		if r.download != nil && resp.StatusCode == http.StatusPartialContent {
+			offset = r.download.offset
+			if total >= 0 {
+				total += offset
+			}
+		}
+		body = newProgressReader(body, offset, total, r.downloadProgress)
+	}
+
+	if r.streaming {
+		return r.consumeStream(resp, body)
+	}
+
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	r.respBody = b
+
+	if r.toJsonPointer != nil {
+		if err := e2json.MustFromJSONByte(r.respBody, r.toJsonPointer); err != nil {
+			return err
+		}
+	}
+
+	if r.decodePointer != nil {
+		if err := r.decode(resp.Header.Get("Content-Type"), r.respBody, r.decodePointer); err != nil {
+			return err
+		}
+	}
+
 	if r.outWriter != nil {
 		if _, err := io.Copy(r.outWriter, bytes.NewReader(r.respBody)); err != nil {
-			r.appendErr(err)
-			return r
+			return err
 		}
 	}
+	return nil
+}
 
-	if r.dumpReqWriter != nil {
-		if b, err := httputil.DumpRequestOut(r.req, r.dumpReqBody); err == nil {
-			if _, err := io.Copy(r.dumpReqWriter, bytes.NewReader(b)); err != nil {
-				r.appendErr(err)
-				return r
-			}
-		} else {
-			r.appendErr(err)
-			return r
+func (r *Context) newRequest(u *url.URL, body func() (io.Reader, int64)) error {
+	var rd io.Reader
+	var size int64 = -1
+	if body != nil {
+		rd, size = body()
+		if size < 0 {
+			size = readerSize(rd)
+		}
+		if r.uploadProgress != nil {
+			rd = newProgressReader(rd, 0, size, r.uploadProgress)
 		}
 	}
 
-	if r.dumpRespWriter != nil {
-		if b, err := httputil.DumpResponse(resp, false); err == nil {
-			if _, err := io.Copy(r.dumpRespWriter, bytes.NewReader(b)); err != nil {
-				r.appendErr(err)
-				return r
-			}
-			if r.dumpRespBody {
-				e2exec.SilentError(r.dumpRespWriter.Write(r.respBody))
+	if r.ctx == nil {
+		return errors.New("e2http: nil context")
+	}
+	r.fromCache = false
+	req, err := http.NewRequestWithContext(context.WithValue(r.ctx, contextKey{}, r), r.method, u.String(), rd)
+	if err != nil {
+		return err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+	if body != nil && r.retry != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			b, _ := body()
+			if rc, ok := b.(io.ReadCloser); ok {
+				return rc, nil
 			}
-		} else {
-			r.appendErr(err)
-			return r
+			return io.NopCloser(b), nil
 		}
 	}
 
-	return r
+	req.Header = r.reqHeaders.Clone()
+	propagateTrace(r.ctx, req.Header)
+	for _, k := range r.delHeaders {
+		req.Header.Del(k)
+	}
+	for _, c := range r.reqCookies {
+		req.AddCookie(c)
+	}
+	if r.download != nil && r.download.path != "" {
+		r.download.prepare(req)
+	}
+	r.req = req
+	return nil
 }
 
 func (r *Context) StatusCode() int {
@@ -438,6 +506,11 @@ func (r *Context) Errors() []error {
 	return r.errs
 }
 
+// Err join all errors of the request, errors.As/errors.Is can be used on the result
+func (r *Context) Err() error {
+	return errors.Join(r.errs...)
+}
+
 func (r *Context) Cookies() []*http.Cookie {
 	return r.respCookies
 }
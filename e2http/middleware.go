@@ -0,0 +1,80 @@
+package e2http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"slices"
+	"sync"
+)
+
+// RoundTripFunc is a function implementing http.RoundTripper
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wrap the transport of a request, e.g.
+//
+//	func(next e2http.RoundTripFunc) e2http.RoundTripFunc {
+//		return func(req *http.Request) (*http.Response, error) {
+//			req.Header.Set("X-Trace-Id", traceId)
+//			return next(req)
+//		}
+//	}
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+var (
+	defaultMiddlewares     []Middleware
+	defaultMiddlewaresLock sync.RWMutex
+)
+
+// UseDefault register process-wide middlewares, they run before the middlewares of each request
+func UseDefault(mw ...Middleware) {
+	defaultMiddlewaresLock.Lock()
+	defer defaultMiddlewaresLock.Unlock()
+	defaultMiddlewares = append(defaultMiddlewares, mw...)
+}
+
+// Use add middlewares to this request, the first one added is the outermost
+func (r *Context) Use(mw ...Middleware) *Context {
+	r.middlewares = append(r.middlewares, mw...)
+	return r
+}
+
+func chainMiddlewares(next RoundTripFunc, mws ...[]Middleware) RoundTripFunc {
+	all := slices.Concat(mws...)
+	for i := len(all) - 1; i >= 0; i-- {
+		next = all[i](next)
+	}
+	return next
+}
+
+// roundTripper build the transport chain: default, client and request middlewares, then the
+// innermost layer which records and dumps exactly what goes on the wire
+func (r *Context) roundTripper() http.RoundTripper {
+	base := r.client.transport
+	if r.transport != nil {
+		base = r.transport
+	}
+
+	wire := func(req *http.Request) (*http.Response, error) {
+		r.req = req
+		if r.dumpReqWriter != nil {
+			if b, err := httputil.DumpRequestOut(req, r.dumpReqBody); err == nil {
+				if _, err := io.Copy(r.dumpReqWriter, bytes.NewReader(b)); err != nil {
+					r.appendErr(err)
+				}
+			} else {
+				r.appendErr(err)
+			}
+		}
+		return base.RoundTrip(req)
+	}
+
+	defaultMiddlewaresLock.RLock()
+	defer defaultMiddlewaresLock.RUnlock()
+	return chainMiddlewares(wire, defaultMiddlewares, r.client.middlewares, r.middlewares)
+}
@@ -0,0 +1,44 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(req.Header.Get("X-Order")))
+	}))
+	defer srv.Close()
+
+	tag := func(v string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				req.Header.Add("X-Order", v)
+				resp, err := next(req)
+				if err == nil {
+					resp.Header.Add("X-Seen", v)
+				}
+				return resp, err
+			}
+		}
+	}
+
+	var dump bytes.Buffer
+	r := Builder(context.TODO()).
+		URL(srv.URL).
+		Use(tag("a"), tag("b")).
+		DumpRequest(&dump, false).
+		Do()
+
+	assert.Empty(t, r.Errors())
+	assert.Equal(t, "a", r.BodyString())
+	assert.Equal(t, []string{"b", "a"}, r.Headers().Values("X-Seen"))
+	assert.Contains(t, dump.String(), "X-Order: a")
+	assert.Contains(t, dump.String(), "X-Order: b")
+}
@@ -0,0 +1,213 @@
+package e2http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MultipartPart is one field of a multipart/form-data body, it becomes a file field when FileName is set
+type MultipartPart struct {
+	Name        string
+	FileName    string
+	ContentType string // default application/octet-stream for file fields
+	Reader      io.Reader
+}
+
+// PostMultipart send values as multipart/form-data ordered by key, *os.File values become file fields
+func (r *Context) PostMultipart(values map[string]io.Reader) *Context {
+	return r.postMultipart(http.MethodPost, mapParts(values))
+}
+
+func (r *Context) PutMultipart(values map[string]io.Reader) *Context {
+	return r.postMultipart(http.MethodPut, mapParts(values))
+}
+
+// PostMultipartParts send parts as multipart/form-data in the given order
+func (r *Context) PostMultipartParts(parts ...*MultipartPart) *Context {
+	return r.postMultipart(http.MethodPost, parts)
+}
+
+func (r *Context) PutMultipartParts(parts ...*MultipartPart) *Context {
+	return r.postMultipart(http.MethodPut, parts)
+}
+
+func mapParts(values map[string]io.Reader) []*MultipartPart {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	parts := make([]*MultipartPart, 0, len(keys))
+	for _, k := range keys {
+		p := &MultipartPart{Name: k, Reader: values[k]}
+		if f, ok := values[k].(*os.File); ok {
+			p.FileName = filepath.Base(f.Name())
+		}
+		parts = append(parts, p)
+	}
+	return parts
+}
+
+func (r *Context) postMultipart(method string, parts []*MultipartPart) *Context {
+	r.Method(method)
+	body := &multipartBody{
+		parts:    parts,
+		boundary: multipart.NewWriter(io.Discard).Boundary(),
+	}
+	r.ContentType("multipart/form-data; boundary=" + body.boundary)
+	r.reqBody = body
+	return r
+}
+
+// errMultipartReplaced end the body of an attempt once a retry rewinds the parts
+var errMultipartReplaced = errors.New("e2http: multipart body replaced by a retry")
+
+// multipartBody stream the parts through a pipe, nothing is buffered in memory,
+// a failing part aborts the request with its error
+type multipartBody struct {
+	parts    []*MultipartPart
+	boundary string
+	lock     sync.Mutex
+	pr       *io.PipeReader
+	done     chan struct{} // closed once the writer goroutine returned
+}
+
+func (m *multipartBody) Read(p []byte) (int, error) {
+	m.lock.Lock()
+	if m.pr == nil {
+		m.open()
+	}
+	pr := m.pr
+	m.lock.Unlock()
+	return pr.Read(p)
+}
+
+// Close stop the writer and close every part reader
+func (m *multipartBody) Close() error {
+	m.lock.Lock()
+	pr := m.pr
+	m.lock.Unlock()
+	if pr != nil {
+		_ = pr.Close()
+	}
+	for _, p := range m.parts {
+		closeReader(p.Reader)
+	}
+	return nil
+}
+
+// open start the writer goroutine, m.lock must be held
+func (m *multipartBody) open() {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = pw.CloseWithError(m.write(pw))
+	}()
+	m.pr, m.done = pr, done
+}
+
+// stop end the body with errMultipartReplaced and wait until the writer no longer reads the parts
+func (m *multipartBody) stop() {
+	m.lock.Lock()
+	if m.pr == nil {
+		// never read, make sure it never starts
+		pr, _ := io.Pipe()
+		_ = pr.CloseWithError(errMultipartReplaced)
+		m.pr = pr
+		m.lock.Unlock()
+		return
+	}
+	pr, done := m.pr, m.done
+	m.lock.Unlock()
+	_ = pr.CloseWithError(errMultipartReplaced)
+	if done != nil {
+		<-done
+	}
+}
+
+func (m *multipartBody) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(m.boundary); err != nil {
+		return err
+	}
+	for _, p := range m.parts {
+		h := make(textproto.MIMEHeader)
+		if p.FileName != "" {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+				escapeQuotes(p.Name), escapeQuotes(p.FileName)))
+			h.Set("Content-Type", "application/octet-stream")
+		} else {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(p.Name)))
+		}
+		if p.ContentType != "" {
+			h.Set("Content-Type", p.ContentType)
+		}
+
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if p.Reader == nil {
+			continue
+		}
+		if _, err := io.Copy(pw, p.Reader); err != nil {
+			return fmt.Errorf("e2http: multipart part %q: %w", p.Name, err)
+		}
+	}
+	return mw.Close()
+}
+
+// replay return a body factory when every part can be rewound, so retries stream again
+func (m *multipartBody) replay() (func() (io.Reader, int64), bool) {
+	offsets := make([]int64, len(m.parts))
+	for i, p := range m.parts {
+		if p.Reader == nil {
+			continue
+		}
+		s, ok := p.Reader.(io.Seeker)
+		if !ok {
+			return nil, false
+		}
+		offset, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, false
+		}
+		offsets[i] = offset
+	}
+
+	// the writer of the previous attempt may still be reading the parts, it is stopped before they are rewound
+	var prev *multipartBody
+	return func() (io.Reader, int64) {
+		if prev != nil {
+			prev.stop()
+		}
+		parts := make([]*MultipartPart, len(m.parts))
+		for i, p := range m.parts {
+			cp := *p
+			if s, ok := p.Reader.(io.Seeker); ok {
+				_, _ = s.Seek(offsets[i], io.SeekStart)
+				cp.Reader = io.NopCloser(p.Reader) // the parts are closed by the outer body
+			}
+			parts[i] = &cp
+		}
+		prev = &multipartBody{parts: parts, boundary: m.boundary}
+		return prev, -1
+	}, true
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,117 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostMultipartParts(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mr, err := req.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := io.ReadAll(p)
+			_, _ = io.WriteString(w, p.FormName()+"|"+p.FileName()+"|"+p.Header.Get("Content-Type")+"|"+string(b)+"\n")
+		}
+	})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var uploaded int64
+	r := Builder(context.TODO()).
+		URL(srv.URL).
+		UploadProgress(func(p Progress) { uploaded = p.Done }).
+		PostMultipartParts(
+			&MultipartPart{Name: "title", Reader: strings.NewReader("hello")},
+			&MultipartPart{Name: "file", FileName: "a.json", ContentType: "application/json", Reader: strings.NewReader("{}")},
+		).
+		Do()
+	assert.Empty(t, r.Errors())
+	assert.Equal(t, "title|||hello\nfile|a.json|application/json|{}\n", r.BodyString())
+	assert.Greater(t, uploaded, int64(0))
+
+	r = Builder(context.TODO()).
+		URL(srv.URL).
+		PostMultipart(map[string]io.Reader{"b": strings.NewReader("2"), "a": strings.NewReader("1")}).
+		Do()
+	assert.Equal(t, "a|||1\nb|||2\n", r.BodyString())
+
+	var failed bool
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		handler(w, req)
+	}))
+	defer flaky.Close()
+	r = Builder(context.TODO()).
+		URL(flaky.URL).
+		Retry(&RetryPolicy{MaxAttempts: 2, RetryOnStatus: []int{http.StatusServiceUnavailable}}).
+		PostMultipartParts(&MultipartPart{Name: "a", Reader: strings.NewReader("again")}).
+		Do()
+	assert.Equal(t, 2, r.Attempts())
+	assert.Equal(t, "a|||again\n", r.BodyString())
+
+	broken := errors.New("disk gone")
+	r = Builder(context.TODO()).
+		URL(srv.URL).
+		PostMultipartParts(&MultipartPart{Name: "file", FileName: "x", Reader: iotest.ErrReader(broken)}).
+		Do()
+	assert.ErrorIs(t, r.Err(), broken)
+}
+
+func TestMultipartRetryRewind(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 1<<18) // 4 MiB
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if attempts.Add(1) == 1 {
+			// give up after a few bytes while the client is still writing the body
+			_, _ = io.CopyN(io.Discard, req.Body, 1024)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mr, err := req.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p, err := mr.NextPart()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, _ := io.ReadAll(p)
+		if !bytes.Equal(payload, b) {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	r := Builder(context.TODO()).
+		URL(srv.URL).
+		Retry(&RetryPolicy{MaxAttempts: 2, RetryOnStatus: []int{http.StatusServiceUnavailable}}).
+		PostMultipartParts(&MultipartPart{Name: "file", FileName: "data.bin", Reader: bytes.NewReader(payload)}).
+		Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, 2, r.Attempts())
+	assert.Equal(t, http.StatusOK, r.StatusCode())
+}
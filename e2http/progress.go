@@ -0,0 +1,98 @@
+package e2http
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+)
+
+// progressInterval is the minimum time between two progress callbacks, the last one is always reported
+const progressInterval = 200 * time.Millisecond
+
+// Progress of a transfer, Total is -1 when the size is unknown, Speed is in bytes per second
+type Progress struct {
+	Done  int64
+	Total int64
+	Speed float64
+}
+
+type ProgressFunc func(p Progress)
+
+// UploadProgress report how much of the request body has been sent
+func (r *Context) UploadProgress(fn ProgressFunc) *Context {
+	r.uploadProgress = fn
+	return r
+}
+
+// DownloadProgress report how much of the response body has been received
+func (r *Context) DownloadProgress(fn ProgressFunc) *Context {
+	r.downloadProgress = fn
+	return r
+}
+
+func readerSize(rd io.Reader) int64 {
+	switch v := rd.(type) {
+	case *bytes.Buffer:
+		return int64(v.Len())
+	case *bytes.Reader:
+		return int64(v.Len())
+	case *strings.Reader:
+		return int64(v.Len())
+	}
+	return -1
+}
+
+type progressReader struct {
+	rd         io.Reader
+	progress   Progress
+	fn         ProgressFunc
+	offset     int64
+	start      time.Time
+	lastReport time.Time
+	finished   bool
+}
+
+// newProgressReader count what is read from rd, offset is the amount already done before, e.g. a resumed download
+func newProgressReader(rd io.Reader, offset, total int64, fn ProgressFunc) io.Reader {
+	p := &progressReader{
+		rd:       rd,
+		progress: Progress{Done: offset, Total: total},
+		fn:       fn,
+		offset:   offset,
+		start:    time.Now(),
+	}
+	if _, ok := rd.(io.Closer); ok {
+		return &progressReadCloser{p}
+	}
+	return p
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.rd.Read(b)
+	p.progress.Done += int64(n)
+	final := err == io.EOF || (p.progress.Total >= 0 && p.progress.Done >= p.progress.Total)
+	if p.finished || (n == 0 && !final) {
+		return n, err
+	}
+
+	now := time.Now()
+	if final || now.Sub(p.lastReport) >= progressInterval {
+		if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 {
+			p.progress.Speed = float64(p.progress.Done-p.offset) / elapsed
+		}
+		p.lastReport = now
+		p.finished = final
+		p.fn(p.progress)
+	}
+	return n, err
+}
+
+// progressReadCloser keep Close reachable, the transport closes the request body through it
+type progressReadCloser struct {
+	*progressReader
+}
+
+func (p *progressReadCloser) Close() error {
+	return p.rd.(io.Closer).Close()
+}
@@ -0,0 +1,229 @@
+package e2http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimitError is returned when the request context ends while waiting for the rate limiter
+type RateLimitError struct {
+	Host string
+	Err  error
+}
+
+func (e *RateLimitError) Error() string {
+	return "e2http: rate limit wait for " + e.Host + ": " + e.Err.Error()
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Err
+}
+
+// HostLimit is the token bucket and concurrency setting of one host, zero values mean unlimited
+type HostLimit struct {
+	Rate        float64 // requests per second
+	Burst       int
+	MaxInFlight int
+}
+
+type RateLimitOptions struct {
+	Global  HostLimit            // shared by all hosts, MaxInFlight is ignored
+	PerHost HostLimit            // applied to each host separately
+	Hosts   map[string]HostLimit // overrides PerHost for the given host names
+}
+
+// hostSweepInterval is how often idle host limiters and circuits are dropped
+const hostSweepInterval = time.Minute
+
+// RateLimiter throttle requests with token buckets and cap the requests in flight per host,
+// a request stays in flight until its response body is closed. Hosts with a full bucket and
+// nothing in flight are forgotten, so crawling many hosts does not grow it without bound
+type RateLimiter struct {
+	opts       RateLimitOptions
+	global     *tokenBucket
+	lock       sync.Mutex
+	hosts      map[string]*hostLimiter
+	swept      time.Time
+	sweepEvery time.Duration
+}
+
+type hostLimiter struct {
+	bucket *tokenBucket
+	slots  chan struct{}
+	active int // requests waiting or in flight, guarded by RateLimiter.lock
+}
+
+func NewRateLimiter(opts RateLimitOptions) *RateLimiter {
+	return &RateLimiter{
+		opts:       opts,
+		global:     newTokenBucket(opts.Global.Rate, opts.Global.Burst),
+		hosts:      make(map[string]*hostLimiter),
+		swept:      time.Now(),
+		sweepEvery: hostSweepInterval,
+	}
+}
+
+// RateLimit throttle this request with l, attach l to a Client with Client.Use(l.Middleware())
+func (r *Context) RateLimit(l *RateLimiter) *Context {
+	return r.Use(l.Middleware())
+}
+
+// acquire the limiter of host name, it is kept until the matching done
+func (l *RateLimiter) acquire(name string) *hostLimiter {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if now := time.Now(); now.Sub(l.swept) >= l.sweepEvery {
+		l.swept = now
+		l.evictIdle(now)
+	}
+	h := l.host(name)
+	h.active++
+	return h
+}
+
+func (l *RateLimiter) done(h *hostLimiter) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	h.active--
+}
+
+// evictIdle drop the hosts a new limiter would behave the same for, l.lock must be held
+func (l *RateLimiter) evictIdle(now time.Time) {
+	for name, h := range l.hosts {
+		if h.active == 0 && h.bucket.full(now) {
+			delete(l.hosts, name)
+		}
+	}
+}
+
+// host return the limiter of host name, l.lock must be held
+func (l *RateLimiter) host(name string) *hostLimiter {
+	if h, ok := l.hosts[name]; ok {
+		return h
+	}
+	hl, ok := l.opts.Hosts[name]
+	if !ok {
+		hl = l.opts.PerHost
+	}
+	h := &hostLimiter{bucket: newTokenBucket(hl.Rate, hl.Burst)}
+	if hl.MaxInFlight > 0 {
+		h.slots = make(chan struct{}, hl.MaxInFlight)
+	}
+	l.hosts[name] = h
+	return h
+}
+
+// Wait block until host may send one more request, release must be called once it is done
+func (l *RateLimiter) Wait(ctx context.Context, host string) (release func(), err error) {
+	h := l.acquire(host)
+	var once sync.Once
+	release = func() { once.Do(func() { l.done(h) }) }
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+			release = func() {
+				once.Do(func() {
+					<-h.slots
+					l.done(h)
+				})
+			}
+		case <-ctx.Done():
+			release()
+			return nil, &RateLimitError{Host: host, Err: ctx.Err()}
+		}
+	}
+	if err := h.bucket.wait(ctx); err != nil {
+		release()
+		return nil, &RateLimitError{Host: host, Err: err}
+	}
+	if err := l.global.wait(ctx); err != nil {
+		release()
+		return nil, &RateLimitError{Host: host, Err: err}
+	}
+	return release, nil
+}
+
+func (l *RateLimiter) Middleware() Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			release, err := l.Wait(req.Context(), req.URL.Hostname())
+			if err != nil {
+				return nil, err
+			}
+			resp, err := next(req)
+			if err != nil || resp == nil || resp.Body == nil {
+				release()
+				return resp, err
+			}
+			resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+			return resp, nil
+		}
+	}
+}
+
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
+
+type tokenBucket struct {
+	lock   sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(max(burst, 1))
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// full report whether the bucket refilled to its burst by now, an unlimited bucket always is
+func (b *tokenBucket) full(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// wait take one token, reserving it ahead when the bucket is empty
+func (b *tokenBucket) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	b.lock.Lock()
+	now := time.Now()
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens--
+	var delay time.Duration
+	if b.tokens < 0 {
+		delay = time.Duration(-b.tokens / b.rate * float64(time.Second))
+	}
+	b.lock.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	if err := sleepContext(ctx, delay); err != nil {
+		b.lock.Lock()
+		b.tokens++
+		b.lock.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package e2http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer srv.Close()
+
+	l := NewRateLimiter(RateLimitOptions{PerHost: HostLimit{Rate: 20, Burst: 1}})
+	cli := NewClient(DefaultClientOptions().WithBaseURL(srv.URL)).Use(l.Middleware())
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Empty(t, cli.Request(context.TODO()).Do().Errors())
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	slow := NewRateLimiter(RateLimitOptions{Global: HostLimit{Rate: 0.1}})
+	_, err := slow.Wait(ctx, "a")
+	assert.NoError(t, err)
+	_, err = slow.Wait(ctx, "a")
+	var rle *RateLimitError
+	assert.True(t, errors.As(err, &rle))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	capped := NewRateLimiter(RateLimitOptions{Hosts: map[string]HostLimit{"b": {MaxInFlight: 1}}})
+	release, err := capped.Wait(context.TODO(), "b")
+	assert.NoError(t, err)
+	_, err = capped.Wait(ctx, "b")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	release()
+	_, err = capped.Wait(context.TODO(), "b")
+	assert.NoError(t, err)
+}
+
+func TestRateLimiterEvictIdleHosts(t *testing.T) {
+	l := NewRateLimiter(RateLimitOptions{PerHost: HostLimit{Rate: 1000, Burst: 1, MaxInFlight: 1}})
+	l.sweepEvery = 0
+
+	releaseA, err := l.Wait(context.TODO(), "a")
+	assert.NoError(t, err)
+	releaseA()
+	releaseB, err := l.Wait(context.TODO(), "b")
+	assert.NoError(t, err)
+	assert.Len(t, l.hosts, 2)
+
+	// a refilled and is idle, b is still in flight
+	time.Sleep(5 * time.Millisecond)
+	releaseC, err := l.Wait(context.TODO(), "c")
+	assert.NoError(t, err)
+	assert.NotContains(t, l.hosts, "a")
+	assert.Contains(t, l.hosts, "b")
+
+	// an evicted host keeps working
+	releaseB()
+	releaseC()
+	time.Sleep(5 * time.Millisecond)
+	releaseA, err = l.Wait(context.TODO(), "a")
+	assert.NoError(t, err)
+	releaseA()
+	assert.Len(t, l.hosts, 1)
+}
@@ -0,0 +1,184 @@
+package e2http
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how Context.Do retries a failed request
+type RetryPolicy struct {
+	MaxAttempts     int           // total attempts, including the first one
+	InitialInterval time.Duration // wait before the first retry
+	MaxInterval     time.Duration // upper bound of a single wait
+	Multiplier      float64       // backoff growth factor between attempts
+	Jitter          float64       // randomization factor 0 ~ 1, 0.2 means +/-20%
+	RetryOnStatus   []int         // response status codes worth another attempt
+	RetryOnError    func(err error) bool
+	MaxRetryAfter   time.Duration // upper bound of a Retry-After wait, default MaxInterval
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:     3,
+		InitialInterval: 200 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+		RetryOnStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// Retry enable automatic retry, a nil policy means DefaultRetryPolicy
+func (r *Context) Retry(p *RetryPolicy) *Context {
+	if p == nil {
+		p = DefaultRetryPolicy()
+	}
+	r.retry = p
+	return r
+}
+
+// Attempts return how many attempts were made by the last Do
+func (r *Context) Attempts() int {
+	return r.attempts
+}
+
+func (p *RetryPolicy) shouldRetry(attempt int, resp *http.Response, err error) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		if p.RetryOnError != nil {
+			return p.RetryOnError(err)
+		}
+		return true
+	}
+	return resp != nil && slices.Contains(p.RetryOnStatus, resp.StatusCode)
+}
+
+// backoff return the wait before the next attempt, attempt starts at 1
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	maxInterval := p.MaxInterval
+	if maxInterval <= 0 {
+		maxInterval = DefaultRetryPolicy().MaxInterval
+	}
+
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			maxRetryAfter := p.MaxRetryAfter
+			if maxRetryAfter <= 0 {
+				maxRetryAfter = maxInterval
+			}
+			return min(d, maxRetryAfter)
+		}
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	wait := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		delta := wait * min(p.Jitter, 1)
+		wait = wait - delta + rand.Float64()*(2*delta) // #nosec G404
+	}
+	return min(time.Duration(wait), maxInterval)
+}
+
+// parseRetryAfter accept both delay-seconds and HTTP-date forms
+func parseRetryAfter(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// replayBody turn rd into a function which returns a fresh reader for every attempt,
+// files and seekable multipart parts are re-read from their current offset, anything else is buffered once
+func replayBody(rd io.Reader) (func() (io.Reader, int64), error) {
+	type fileReader interface {
+		io.ReaderAt
+		io.Seeker
+		Stat() (os.FileInfo, error)
+	}
+
+	switch v := rd.(type) {
+	case *multipartBody:
+		if replay, ok := v.replay(); ok {
+			return replay, nil
+		}
+	case *bytes.Buffer:
+		b := v.Bytes()
+		return func() (io.Reader, int64) { return bytes.NewReader(b), int64(len(b)) }, nil
+	case fileReader:
+		fi, err := v.Stat()
+		if err != nil {
+			return nil, err
+		}
+		offset, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		size := fi.Size() - offset
+		return func() (io.Reader, int64) { return io.NewSectionReader(v, offset, size), size }, nil
+	}
+
+	b, err := io.ReadAll(rd)
+	closeReader(rd)
+	if err != nil {
+		return nil, err
+	}
+	return func() (io.Reader, int64) { return bytes.NewReader(b), int64(len(b)) }, nil
+}
+
+func closeReader(rd io.Reader) {
+	if c, ok := rd.(io.Closer); ok && c != nil {
+		_ = c.Close()
+	}
+}
+
+func drainBody(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, 64<<10))
+	_ = body.Close()
+}
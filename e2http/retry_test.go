@@ -0,0 +1,66 @@
+package e2http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(b)
+	}))
+	defer srv.Close()
+
+	r := Builder(context.TODO()).
+		URL(srv.URL).
+		Retry(&RetryPolicy{MaxAttempts: 5, InitialInterval: time.Millisecond, RetryOnStatus: []int{http.StatusServiceUnavailable}}).
+		PostJSON(strings.NewReader(`{"hello":"world"}`)).
+		Do()
+
+	assert.Empty(t, r.Errors())
+	assert.Equal(t, http.StatusOK, r.StatusCode())
+	assert.Equal(t, 3, r.Attempts())
+	assert.Equal(t, `{"hello":"world"}`, r.BodyString())
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	p := DefaultRetryPolicy()
+	p.InitialInterval = time.Millisecond
+	r := Builder(context.TODO()).URL(srv.URL).Retry(p).Do()
+
+	assert.Equal(t, http.StatusBadGateway, r.StatusCode())
+	assert.Equal(t, p.MaxAttempts, r.Attempts())
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	d, ok := parseRetryAfter("3")
+	assert.True(t, ok)
+	assert.Equal(t, 3*time.Second, d)
+
+	d, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.Greater(t, d, 59*time.Minute)
+
+	_, ok = parseRetryAfter("soon")
+	assert.False(t, ok)
+}
@@ -0,0 +1,162 @@
+package e2http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+// SigV4 sign requests with AWS Signature Version 4, e.g. for an e2aws session
+//
+//	sess := e2aws.NewSession("ap-east-1")
+//	e2http.Builder(ctx).URL(u).Auth(e2http.NewSigV4(sess.Config.Credentials, "s3", "ap-east-1")).Do()
+type SigV4 struct {
+	creds   *credentials.Credentials
+	signer  *v4.Signer
+	service string
+	region  string
+}
+
+func NewSigV4(creds *credentials.Credentials, service, region string) *SigV4 {
+	// the body is already buffered by Authorize, the signer only hashes it
+	signer := v4.NewSigner(creds, func(s *v4.Signer) { s.DisableRequestBodyOverwrite = true })
+	return &SigV4{creds: creds, signer: signer, service: service, region: region}
+}
+
+func (s *SigV4) Authorize(req *http.Request) error {
+	body, err := peekRequestBody(req)
+	if err != nil {
+		return err
+	}
+	var rd io.ReadSeeker
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	_, err = s.signer.Sign(req, rd, s.service, s.region, time.Now())
+	return err
+}
+
+// Invalidate expire the credentials so they are fetched again before the retry
+func (s *SigV4) Invalidate(*http.Request) bool {
+	s.creds.Expire()
+	return true
+}
+
+// HMACOptions describe the canonical request and the header carrying the signature
+type HMACOptions struct {
+	KeyID  string
+	Secret []byte
+	Hash   func() hash.Hash // default sha256.New
+	Scheme string           // first word of the Authorization value, default HMAC-SHA256
+	// SignedHeaders are part of the canonical request in this order, "Host" is taken from the URL,
+	// default Host and DateHeader
+	SignedHeaders []string
+	DateHeader    string // set to the signing time unless present, default X-Date
+	// BodyHashHeader carry the hex hash of the body when set, e.g. X-Content-SHA256
+	BodyHashHeader string
+	Base64         bool // encode the signature with base64 instead of hex
+	// Canonical override the canonical request, default
+	//
+	//	METHOD \n escaped path \n sorted query \n lower(header):value ... \n hex(hash(body))
+	Canonical func(req *http.Request, body []byte, signedHeaders []string) string
+}
+
+// HMACSigner sign the canonical request with HMAC and send
+// "Authorization: <Scheme> KeyId=<id>, SignedHeaders=<a;b>, Signature=<sig>"
+type HMACSigner struct {
+	opts HMACOptions
+}
+
+func NewHMACSigner(opts HMACOptions) *HMACSigner {
+	if opts.Hash == nil {
+		opts.Hash = sha256.New
+	}
+	if opts.Scheme == "" {
+		opts.Scheme = "HMAC-SHA256"
+	}
+	if opts.DateHeader == "" {
+		opts.DateHeader = "X-Date"
+	}
+	if len(opts.SignedHeaders) == 0 {
+		opts.SignedHeaders = []string{"Host", opts.DateHeader}
+	}
+	if opts.Canonical == nil {
+		opts.Canonical = canonicalRequest(opts.Hash)
+	}
+	return &HMACSigner{opts: opts}
+}
+
+func (s *HMACSigner) Authorize(req *http.Request) error {
+	body, err := peekRequestBody(req)
+	if err != nil {
+		return err
+	}
+	if req.Header.Get(s.opts.DateHeader) == "" {
+		req.Header.Set(s.opts.DateHeader, time.Now().UTC().Format(http.TimeFormat))
+	}
+	if s.opts.BodyHashHeader != "" {
+		h := s.opts.Hash()
+		h.Write(body)
+		req.Header.Set(s.opts.BodyHashHeader, hex.EncodeToString(h.Sum(nil)))
+	}
+
+	mac := hmac.New(s.opts.Hash, s.opts.Secret)
+	mac.Write([]byte(s.opts.Canonical(req, body, s.opts.SignedHeaders)))
+	sum := mac.Sum(nil)
+	sig := hex.EncodeToString(sum)
+	if s.opts.Base64 {
+		sig = base64.StdEncoding.EncodeToString(sum)
+	}
+
+	names := make([]string, len(s.opts.SignedHeaders))
+	for i, h := range s.opts.SignedHeaders {
+		names[i] = strings.ToLower(h)
+	}
+	req.Header.Set("Authorization", s.opts.Scheme+" KeyId="+s.opts.KeyID+
+		", SignedHeaders="+strings.Join(names, ";")+", Signature="+sig)
+	return nil
+}
+
+// Invalidate return false, a rejected signature does not change on a retry
+func (s *HMACSigner) Invalidate(*http.Request) bool {
+	return false
+}
+
+func canonicalRequest(newHash func() hash.Hash) func(req *http.Request, body []byte, signedHeaders []string) string {
+	return func(req *http.Request, body []byte, signedHeaders []string) string {
+		var b strings.Builder
+		b.WriteString(req.Method)
+		b.WriteByte('\n')
+		b.WriteString(req.URL.EscapedPath())
+		b.WriteByte('\n')
+		b.WriteString(req.URL.Query().Encode())
+		b.WriteByte('\n')
+		for _, name := range signedHeaders {
+			value := req.Header.Get(name)
+			if strings.EqualFold(name, "Host") {
+				value = req.Host
+				if value == "" {
+					value = req.URL.Host
+				}
+			}
+			b.WriteString(strings.ToLower(name))
+			b.WriteByte(':')
+			b.WriteString(strings.TrimSpace(value))
+			b.WriteByte('\n')
+		}
+		h := newHash()
+		h.Write(body)
+		b.WriteString(hex.EncodeToString(h.Sum(nil)))
+		return b.String()
+	}
+}
@@ -0,0 +1,100 @@
+package e2http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestAuth(t *testing.T) {
+	// https://www.rfc-editor.org/rfc/rfc7616#section-3.9.1
+	old := digestCnonce
+	digestCnonce = func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" }
+	defer func() { digestCnonce = old }()
+
+	var auths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auths = append(auths, req.Header.Get("Authorization"))
+		if req.Header.Get("Authorization") == "" {
+			w.Header().Add("WWW-Authenticate", `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=MD5, `+
+				`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+			w.Header().Add("WWW-Authenticate", `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, `+
+				`nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	d := NewDigestAuth("Mufasa", "Circle of Life")
+	r := Builder(context.TODO()).URL(srv.URL + "/dir/index.html").Auth(d).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "ok", r.BodyString())
+	assert.Len(t, auths, 2)
+	params := parseAuthParams(strings.TrimPrefix(auths[1], "Digest "))
+	assert.Equal(t, "SHA-256", params["algorithm"])
+	assert.Equal(t, "00000001", params["nc"])
+	assert.Equal(t, "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1", params["response"])
+	assert.Equal(t, "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS", params["opaque"])
+
+	// the challenge is reused without another round trip
+	r = Builder(context.TODO()).URL(srv.URL + "/dir/index.html").Auth(d).Do()
+	assert.Equal(t, "ok", r.BodyString())
+	assert.Len(t, auths, 3)
+	assert.Equal(t, "00000002", parseAuthParams(strings.TrimPrefix(auths[2], "Digest "))["nc"])
+}
+
+func TestSigV4(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = req
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	creds := credentials.NewStaticCredentials("AKID", "SECRET", "")
+	r := Builder(context.TODO()).URL(srv.URL + "/bucket/key").Method(http.MethodPut).
+		Auth(NewSigV4(creds, "s3", "us-east-1")).PostRaw(strings.NewReader("data")).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "data", body)
+	assert.True(t, strings.HasPrefix(got.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/"))
+	assert.Contains(t, got.Header.Get("Authorization"), "/us-east-1/s3/aws4_request")
+	sum := sha256.Sum256([]byte("data"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), got.Header.Get("X-Amz-Content-Sha256"))
+}
+
+func TestHMACSigner(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = req
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	s := NewHMACSigner(HMACOptions{KeyID: "partner", Secret: []byte("k"), BodyHashHeader: "X-Content-SHA256"})
+	r := Builder(context.TODO()).URL(srv.URL+"/v1/orders").Query("b", "2").Query("a", "1").
+		SetHeader("X-Date", "Mon, 02 Jan 2006 15:04:05 GMT").
+		Auth(s).PostJSON(strings.NewReader(`{}`)).Do()
+	assert.NoError(t, r.Err())
+
+	bodySum := sha256.Sum256([]byte(`{}`))
+	canonical := "POST\n/v1/orders\na=1&b=2\nhost:" + u.Host + "\nx-date:Mon, 02 Jan 2006 15:04:05 GMT\n" + hex.EncodeToString(bodySum[:])
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write([]byte(canonical))
+	assert.Equal(t, "HMAC-SHA256 KeyId=partner, SignedHeaders=host;x-date, Signature="+hex.EncodeToString(mac.Sum(nil)),
+		got.Header.Get("Authorization"))
+	assert.Equal(t, hex.EncodeToString(bodySum[:]), got.Header.Get("X-Content-SHA256"))
+}
@@ -0,0 +1,180 @@
+package e2http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrStopStream can be returned by an SSE or NDJSON callback to stop reading without reporting an error
+var ErrStopStream = errors.New("e2http: stop stream")
+
+const defaultSSERetry = 3 * time.Second
+
+// Event is one server-sent event, https://html.spec.whatwg.org/multipage/server-sent-events.html
+type Event struct {
+	ID    string // last event ID seen on the stream, sent back as Last-Event-ID when reconnecting
+	Event string // event type, "message" when the server sets none
+	Data  string
+	Retry time.Duration // reconnection delay requested by the server, 0 when not set by this event
+}
+
+type sseState struct {
+	fn          func(Event) error
+	lastEventID string
+	retry       time.Duration
+	stopped     bool // fn asked to stop or failed, no reconnection
+}
+
+// SSE consume the response as a text/event-stream and call fn for every event, Do reconnects with
+// Last-Event-ID whenever the stream ends and only returns once fn fails, the server answers with
+// something other than 200, or the context ends; a cancelled context is not reported as an error
+func (r *Context) SSE(fn func(Event) error) *Context {
+	r.sse = &sseState{fn: fn, retry: defaultSSERetry}
+	r.streaming = true
+	r.streamFn = r.sse.consume
+	r.errorOnNon2xx = true
+	r.reqHeaders.Set("Accept", "text/event-stream")
+	r.reqHeaders.Set("Cache-Control", "no-cache")
+	return r
+}
+
+// LastEventID return the ID of the last event received by SSE
+func (r *Context) LastEventID() string {
+	if r.sse == nil {
+		return ""
+	}
+	return r.sse.lastEventID
+}
+
+func (r *Context) doSSE() *Context {
+	// errors recorded while building the request are final, nothing is sent or retried
+	if len(r.errs) > 0 {
+		return r
+	}
+	for {
+		// only the errors of the previous attempt are dropped
+		r.errs = nil
+		r.respStatusCode = 0
+		if r.sse.lastEventID != "" {
+			r.reqHeaders.Set("Last-Event-ID", r.sse.lastEventID)
+		}
+		r.do()
+
+		if errors.Is(r.ctx.Err(), context.Canceled) {
+			r.errs = nil
+			return r
+		}
+		if r.sse.stopped || r.ctx.Err() != nil {
+			return r
+		}
+		// a network error or the end of a 200 stream reconnects, any other answer is final
+		if r.respStatusCode != 0 && r.respStatusCode != http.StatusOK {
+			return r
+		}
+		if err := sleepContext(r.ctx, r.sse.retry); err != nil {
+			if errors.Is(err, context.Canceled) {
+				r.errs = nil
+			}
+			return r
+		}
+	}
+}
+
+// consume parse the event stream, a trailing event without its blank line is discarded as the spec requires
+func (s *sseState) consume(rd io.Reader) error {
+	br := bufio.NewReader(rd)
+	var (
+		eventType string
+		data      strings.Builder
+		retry     time.Duration
+	)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if data.Len() > 0 {
+				ev := Event{
+					ID:    s.lastEventID,
+					Event: eventType,
+					Data:  strings.TrimSuffix(data.String(), "\n"),
+					Retry: retry,
+				}
+				if ev.Event == "" {
+					ev.Event = "message"
+				}
+				if err := s.fn(ev); err != nil {
+					s.stopped = true
+					if errors.Is(err, ErrStopStream) {
+						return nil
+					}
+					return err
+				}
+			}
+			eventType, retry = "", 0
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+				s.retry = retry
+			}
+		}
+	}
+}
+
+// NDJSON return a Stream consumer decoding one JSON value of type T per line, e.g.
+//
+//	e2http.Builder(ctx).URL(u).Stream(e2http.NDJSON(func(v Item) error { ... })).Do()
+//
+// returning ErrStopStream from fn, or cancelling the request context, ends the stream without an error
+func NDJSON[T any](fn func(T) error) func(io.Reader) error {
+	return func(rd io.Reader) error {
+		dec := json.NewDecoder(rd)
+		for {
+			var v T
+			if err := dec.Decode(&v); err != nil {
+				if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+					return nil
+				}
+				return err
+			}
+			if err := fn(v); err != nil {
+				if errors.Is(err, ErrStopStream) {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package e2http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSE(t *testing.T) {
+	var lastIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lastIDs = append(lastIDs, req.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(lastIDs) == 1 {
+			_, _ = io.WriteString(w, ": comment\nretry: 10\n\nid: 1\ndata: a\ndata: b\n\nevent: ping\r\ndata:c\r\n\ndata: incomplete\n")
+			return
+		}
+		_, _ = io.WriteString(w, "id: 2\ndata: d\n\n")
+	}))
+	defer srv.Close()
+
+	var events []Event
+	r := Builder(context.TODO()).URL(srv.URL).SSE(func(ev Event) error {
+		events = append(events, ev)
+		if ev.ID == "2" {
+			return ErrStopStream
+		}
+		return nil
+	}).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, []string{"", "1"}, lastIDs)
+	assert.Equal(t, []Event{
+		{ID: "1", Event: "message", Data: "a\nb"},
+		{ID: "1", Event: "ping", Data: "c"},
+		{ID: "2", Event: "message", Data: "d"},
+	}, events)
+	assert.Equal(t, "2", r.LastEventID())
+}
+
+func TestSSEContextCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	var got []string
+	done := make(chan *Context)
+	go func() {
+		done <- Builder(ctx).URL(srv.URL).SSE(func(ev Event) error {
+			got = append(got, ev.Data)
+			cancel()
+			return nil
+		}).Do()
+	}()
+
+	select {
+	case r := <-done:
+		assert.NoError(t, r.Err())
+		assert.Equal(t, []string{"first"}, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("SSE did not stop on context cancel")
+	}
+}
+
+func TestSSEBuildError(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+	r := Builder(ctx).URL(srv.URL).Resolve("example.test", "not-an-ip").SSE(func(e Event) error { return nil }).Do()
+	assert.Error(t, r.Err())
+	assert.Error(t, Builder(context.TODO()).URL(srv.URL).Resolve("example.test", "not-an-ip").Do().Err())
+	assert.NoError(t, ctx.Err())
+	assert.Zero(t, hits.Load())
+}
+
+func TestNDJSON(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = io.WriteString(w, "{\"n\":1}\n{\"n\":2}\n{\"n\":3}\n")
+	}))
+	defer srv.Close()
+
+	type item struct {
+		N int `json:"n"`
+	}
+	var got []int
+	r := Builder(context.TODO()).URL(srv.URL).Stream(NDJSON(func(v item) error {
+		got = append(got, v.N)
+		if v.N == 2 {
+			return ErrStopStream
+		}
+		return nil
+	})).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, []int{1, 2}, got)
+}
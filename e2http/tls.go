@@ -0,0 +1,146 @@
+package e2http
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+)
+
+var ErrPinMismatch = errors.New("no certificate matches the pinned SPKI hashes")
+
+// TLSError is recorded when TLS material can not be loaded or the pinning check fails
+type TLSError struct {
+	Op  string
+	Err error
+}
+
+func (e *TLSError) Error() string {
+	return "e2http: tls " + e.Op + ": " + e.Err.Error()
+}
+
+func (e *TLSError) Unwrap() error {
+	return e.Err
+}
+
+func (r *Context) tlsConfig() *tls.Config {
+	t := r.ownTransport()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return t.TLSClientConfig
+}
+
+// ClientCertPEM present a client certificate for mTLS
+func (r *Context) ClientCertPEM(certPEM, keyPEM []byte) *Context {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		r.appendErr(&TLSError{Op: "load client certificate", Err: err})
+		return r
+	}
+	c := r.tlsConfig()
+	c.Certificates = append(c.Certificates, cert)
+	return r
+}
+
+func (r *Context) ClientCertFile(certFile, keyFile string) *Context {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		r.appendErr(&TLSError{Op: "load client certificate", Err: err})
+		return r
+	}
+	c := r.tlsConfig()
+	c.Certificates = append(c.Certificates, cert)
+	return r
+}
+
+// RootCAPEM trust the CA certificates in pem in addition to the system roots
+func (r *Context) RootCAPEM(pem []byte) *Context {
+	c := r.tlsConfig()
+	if c.RootCAs == nil {
+		if pool, err := x509.SystemCertPool(); err == nil {
+			c.RootCAs = pool
+		} else {
+			c.RootCAs = x509.NewCertPool()
+		}
+	}
+	if !c.RootCAs.AppendCertsFromPEM(pem) {
+		r.appendErr(&TLSError{Op: "load root CA", Err: errors.New("no certificate found in PEM data")})
+	}
+	return r
+}
+
+func (r *Context) RootCAFile(path string) *Context {
+	b, err := os.ReadFile(path) // #nosec G304
+	if err != nil {
+		r.appendErr(&TLSError{Op: "load root CA", Err: err})
+		return r
+	}
+	return r.RootCAPEM(b)
+}
+
+// MinTLSVersion e.g. tls.VersionTLS13
+func (r *Context) MinTLSVersion(v uint16) *Context {
+	r.tlsConfig().MinVersion = v
+	return r
+}
+
+// ServerName override the SNI and the name the server certificate is verified against
+func (r *Context) ServerName(name string) *Context {
+	r.tlsConfig().ServerName = name
+	return r
+}
+
+func (r *Context) InsecureSkipVerify(skip bool) *Context {
+	r.tlsConfig().InsecureSkipVerify = skip // #nosec G402
+	return r
+}
+
+// PinSPKI only accept servers whose verified certificate chain contains a public key with one of the
+// base64 encoded SHA-256 hashes, the same format as HPKP and curl --pinnedpubkey, "sha256//" prefix is allowed.
+// With InsecureSkipVerify there is no verified chain and only the leaf certificate is checked
+func (r *Context) PinSPKI(hashes ...string) *Context {
+	pins := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		h = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(h), "sha256//"), "sha256/")
+		if _, err := base64.StdEncoding.DecodeString(h); err != nil {
+			r.appendErr(&TLSError{Op: "pin", Err: err})
+			return r
+		}
+		pins = append(pins, h)
+	}
+
+	c := r.tlsConfig()
+	verify := c.VerifyConnection
+	c.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verify != nil {
+			if err := verify(cs); err != nil {
+				return err
+			}
+		}
+		// the peer may send any extra certificate, only those chaining to a trusted root count
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				if slices.Contains(pins, SPKIHash(cert)) {
+					return nil
+				}
+			}
+		}
+		// no verified chain means InsecureSkipVerify, the leaf is the only certificate the peer proved to own
+		if len(cs.VerifiedChains) == 0 && len(cs.PeerCertificates) > 0 && slices.Contains(pins, SPKIHash(cs.PeerCertificates[0])) {
+			return nil
+		}
+		return &TLSError{Op: "pin", Err: ErrPinMismatch}
+	}
+	return r
+}
+
+// SPKIHash return the base64 encoded SHA-256 hash of the certificate public key, usable by PinSPKI
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
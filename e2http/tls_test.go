@@ -0,0 +1,124 @@
+package e2http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinSPKI(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("pinned"))
+	}))
+	defer srv.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+
+	r := Builder(context.TODO()).
+		URL(srv.URL).
+		RootCAPEM(caPEM).
+		PinSPKI("sha256//" + SPKIHash(srv.Certificate())).
+		Do()
+	assert.Empty(t, r.Errors())
+	assert.Equal(t, "pinned", r.BodyString())
+
+	r = Builder(context.TODO()).
+		URL(srv.URL).
+		RootCAPEM(caPEM).
+		PinSPKI("47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=").
+		Do()
+	var te *TLSError
+	assert.True(t, errors.As(r.Err(), &te))
+	assert.ErrorIs(t, r.Err(), ErrPinMismatch)
+
+	r = Builder(context.TODO()).URL(srv.URL).ClientCertPEM([]byte("bad"), []byte("bad"))
+	assert.True(t, errors.As(r.Err(), &te))
+	assert.Equal(t, "load client certificate", te.Op)
+}
+
+// selfSignedCert for 127.0.0.1, valid for an hour
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "evil"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestPinSPKIExtraCertificate(t *testing.T) {
+	legit := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer legit.Close()
+	pin := SPKIHash(legit.Certificate())
+
+	// a server with its own trusted certificate appends the pinned one to its chain
+	evilCert := selfSignedCert(t)
+	evilCert.Certificate = append(evilCert.Certificate, legit.Certificate().Raw)
+	evil := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("evil"))
+	}))
+	evil.TLS = &tls.Config{Certificates: []tls.Certificate{evilCert}}
+	evil.StartTLS()
+	defer evil.Close()
+
+	evilPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: evilCert.Certificate[0]})
+	r := Builder(context.TODO()).URL(evil.URL).RootCAPEM(evilPEM).PinSPKI(pin).Do()
+	assert.ErrorIs(t, r.Err(), ErrPinMismatch)
+	assert.Empty(t, r.BodyString())
+
+	// without verification only the leaf counts
+	r = Builder(context.TODO()).URL(evil.URL).InsecureSkipVerify(true).PinSPKI(pin).Do()
+	assert.ErrorIs(t, r.Err(), ErrPinMismatch)
+
+	leaf, err := x509.ParseCertificate(evilCert.Certificate[0])
+	assert.NoError(t, err)
+	r = Builder(context.TODO()).URL(evil.URL).InsecureSkipVerify(true).PinSPKI(SPKIHash(leaf)).Do()
+	assert.NoError(t, r.Err())
+	assert.Equal(t, "evil", r.BodyString())
+}
+
+func TestTLSErrorsNotSent(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	for _, r := range []*Context{
+		Builder(context.TODO()).URL(srv.URL).InsecureSkipVerify(true).PinSPKI("!!notbase64"),
+		Builder(context.TODO()).URL(srv.URL).InsecureSkipVerify(true).ClientCertPEM([]byte("bad"), []byte("bad")),
+		Builder(context.TODO()).URL(srv.URL).InsecureSkipVerify(true).RootCAPEM([]byte("bad")),
+		Builder(context.TODO()).URL(srv.URL).InsecureSkipVerify(true).RootCAFile(filepath.Join(t.TempDir(), "missing.pem")),
+	} {
+		var te *TLSError
+		assert.True(t, errors.As(r.Do().Err(), &te))
+		assert.Zero(t, r.StatusCode())
+	}
+	assert.Zero(t, hits.Load())
+}
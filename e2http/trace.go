@@ -0,0 +1,27 @@
+package e2http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/e2u/e2util/e2context"
+)
+
+// propagateTrace forward the request id and W3C trace context of ctx, e.g. the context of a gin
+// request passed through e2gin's trace middleware, each outbound request is a new span of the trace,
+// headers set on the request are kept and RemoveHeader drops them
+func propagateTrace(ctx context.Context, h http.Header) {
+	t, ok := e2context.TraceFrom(ctx)
+	if !ok {
+		return
+	}
+	if t.RequestID != "" && h.Get(e2context.RequestIDHeader) == "" {
+		h.Set(e2context.RequestIDHeader, t.RequestID)
+	}
+	if t.TraceParent.IsValid() && h.Get(e2context.TraceparentHeader) == "" {
+		h.Set(e2context.TraceparentHeader, t.TraceParent.Child().String())
+		if t.TraceState != "" {
+			h.Set(e2context.TracestateHeader, t.TraceState)
+		}
+	}
+}
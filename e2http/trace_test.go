@@ -0,0 +1,36 @@
+package e2http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/e2u/e2util/e2context"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPropagateTrace(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = req.Header.Clone()
+	}))
+	defer srv.Close()
+
+	tp := e2context.NewTraceParent()
+	ctx := e2context.WithTrace(context.TODO(), e2context.Trace{RequestID: "req-1", TraceParent: tp, TraceState: "vendor=1"})
+	assert.NoError(t, Builder(ctx).URL(srv.URL).Do().Err())
+	assert.Equal(t, "req-1", got.Get("X-Request-Id"))
+	out, err := e2context.ParseTraceParent(got.Get("traceparent"))
+	assert.NoError(t, err)
+	assert.Equal(t, tp.TraceID, out.TraceID)
+	assert.NotEqual(t, tp.SpanID, out.SpanID)
+	assert.Equal(t, "vendor=1", got.Get("tracestate"))
+
+	assert.NoError(t, Builder(ctx).URL(srv.URL).SetHeader("X-Request-Id", "mine").RemoveHeader("traceparent").Do().Err())
+	assert.Equal(t, "mine", got.Get("X-Request-Id"))
+	assert.Empty(t, got.Get("traceparent"))
+
+	assert.NoError(t, Builder(context.TODO()).URL(srv.URL).Do().Err())
+	assert.Empty(t, got.Get("X-Request-Id"))
+}
@@ -0,0 +1,190 @@
+package e2http
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var pathParamRegex = regexp.MustCompile(`{[^{}/]*}`)
+
+// BaseURL set the base which relative URL and Path are resolved against
+func (r *Context) BaseURL(u string) *Context {
+	if v, err := url.Parse(u); err == nil {
+		r.baseURL = v
+	} else {
+		r.appendErr(err)
+	}
+	return r
+}
+
+// Path append a path template to the base url, each {name} is replaced in order by the
+// path escaped args, e.g. Path("/users/{id}/orders", id)
+func (r *Context) Path(tmpl string, args ...any) *Context {
+	if n := len(pathParamRegex.FindAllString(tmpl, -1)); n != len(args) {
+		r.appendErr(fmt.Errorf("e2http: path %q wants %d params, got %d", tmpl, n, len(args)))
+		return r
+	}
+	var idx int
+	r.path = pathParamRegex.ReplaceAllStringFunc(tmpl, func(string) string {
+		v := url.PathEscape(fmt.Sprint(args[idx]))
+		idx++
+		return v
+	})
+	return r
+}
+
+// Query add a query parameter, existing parameters in the url are kept
+func (r *Context) Query(key, value string) *Context {
+	if r.query == nil {
+		r.query = make(url.Values)
+	}
+	r.query.Add(key, value)
+	return r
+}
+
+// QueryStruct add the fields of struct v as query parameters, using the `url` tag,
+// e.g. `url:"page,omitempty"`, a tag of "-" skips the field
+func (r *Context) QueryStruct(v any) *Context {
+	if r.query == nil {
+		r.query = make(url.Values)
+	}
+	if err := encodeQueryStruct(r.query, reflect.ValueOf(v)); err != nil {
+		r.appendErr(err)
+	}
+	return r
+}
+
+// requestURL combine url, base url, path and query into the final request url
+func (r *Context) requestURL() (*url.URL, error) {
+	var u url.URL
+	switch {
+	case r.url == nil && r.baseURL == nil:
+		return nil, errors.New("e2http: request url is empty")
+	case r.url == nil:
+		u = *r.baseURL
+	case r.baseURL != nil && !r.url.IsAbs():
+		u = *r.baseURL.ResolveReference(r.url)
+	default:
+		u = *r.url
+	}
+
+	if r.path != "" {
+		rawPath := strings.TrimRight(u.EscapedPath(), "/") + "/" + strings.TrimLeft(r.path, "/")
+		p, err := url.PathUnescape(rawPath)
+		if err != nil {
+			return nil, err
+		}
+		u.Path = p
+		u.RawPath = rawPath
+	}
+
+	if len(r.query) > 0 {
+		q := u.Query()
+		for k, vs := range r.query {
+			q[k] = append(q[k], vs...)
+		}
+		u.RawQuery = q.Encode()
+	}
+	return &u, nil
+}
+
+func encodeQueryStruct(values url.Values, rv reflect.Value) error {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("e2http: QueryStruct wants a struct, got %s", rv.Kind())
+	}
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag := sf.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		omitEmpty := strings.Contains(opts, "omitempty")
+		fv := rv.Field(i)
+
+		if sf.Anonymous && name == "" {
+			if err := encodeQueryStruct(values, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		if omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		for fv.Kind() == reflect.Pointer {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Pointer {
+			continue
+		}
+
+		if (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len(); j++ {
+				values.Add(name, queryValue(fv.Index(j)))
+			}
+			continue
+		}
+		values.Add(name, queryValue(fv))
+	}
+	return nil
+}
+
+func queryValue(v reflect.Value) string {
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case time.Time:
+			return x.Format(time.RFC3339)
+		case encoding.TextMarshaler:
+			if b, err := x.MarshalText(); err == nil {
+				return string(b)
+			}
+		case fmt.Stringer:
+			return x.String()
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,43 @@
+package e2http
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_requestURL(t *testing.T) {
+	type Paging struct {
+		Page int `url:"page,omitempty"`
+		Size int `url:"size,omitempty"`
+	}
+	type Filter struct {
+		Paging
+		Name   string    `url:"name"`
+		Tags   []string  `url:"tag"`
+		Since  time.Time `url:"since,omitempty"`
+		Hidden string    `url:"-"`
+		Active *bool     `url:"active,omitempty"`
+	}
+
+	r := Builder(context.TODO()).
+		BaseURL("https://api.example.com/v1/").
+		URL("?lang=en").
+		Path("/users/{id}/orders/{name}", 42, "a b/c").
+		Query("sort", "desc").
+		QueryStruct(&Filter{Paging: Paging{Page: 2}, Name: "x&y", Tags: []string{"a", "b"}, Hidden: "secret"})
+	assert.Empty(t, r.Errors())
+
+	u, err := r.requestURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://api.example.com/v1/users/42/orders/a%20b%2Fc?lang=en&name=x%26y&page=2&sort=desc&tag=a&tag=b", u.String())
+
+	r = Builder(context.TODO()).Path("/users/{id}")
+	assert.Len(t, r.Errors(), 1)
+
+	u, err = Builder(context.TODO()).URL("https://example.com/search?q=go").Query("q", "e2u").requestURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/search?q=go&q=e2u", u.String())
+}
@@ -63,6 +63,7 @@ func CloneLogrus(orig *logrus.Logger) *logrus.Logger {
 	newLogger.SetOutput(orig.Out)
 	newLogger.SetReportCaller(orig.ReportCaller)
 	newLogger.SetLevel(orig.Level)
+	newLogger.AddHook(&TraceHook{})
 	return newLogger
 }
 
@@ -160,6 +161,7 @@ func NewLogger(cfg *Config) *logrus.Logger {
 		log.SetOutput(os.Stdout)
 	}
 	log.AddHook(&SeqHook{})
+	log.AddHook(&TraceHook{})
 
 	log.Trace("active logrus TRACE level")
 	log.Debug("active logrus DEBUG level")
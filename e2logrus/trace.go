@@ -0,0 +1,29 @@
+package e2logrus
+
+import (
+	"github.com/e2u/e2util/e2context"
+	"github.com/sirupsen/logrus"
+)
+
+// TraceHook stamp request_id, trace_id and span_id on entries logged with a context carrying an
+// e2context.Trace, e.g. logger.WithContext(c.Request.Context()).Info("done")
+type TraceHook struct{}
+
+func (h *TraceHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *TraceHook) Fire(entry *logrus.Entry) error {
+	t, ok := e2context.TraceFrom(entry.Context)
+	if !ok {
+		return nil
+	}
+	if t.RequestID != "" {
+		entry.Data["request_id"] = t.RequestID
+	}
+	if t.TraceParent.IsValid() {
+		entry.Data["trace_id"] = t.TraceParent.TraceIDString()
+		entry.Data["span_id"] = t.TraceParent.SpanIDString()
+	}
+	return nil
+}
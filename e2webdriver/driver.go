@@ -132,35 +132,14 @@ func downloadAndUnzip(ctx context.Context, url string, localDir string) (string,
 		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return "", err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer e2exec.MustClose(resp.Body)
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
-	}
-
 	zipFile := filepath.Join(localDir, fileName)
-	out, err := os.OpenFile(zipFile, os.O_CREATE|os.O_SYNC|os.O_RDWR|os.O_TRUNC, 0644)
-	if err != nil {
-		logrus.Errorf("open file error=%v", err)
-		return "", err
-	}
-	defer e2exec.MustClose(out)
-
-	if _, err := out.ReadFrom(resp.Body); err != nil {
-		logrus.Errorf("read file error=%v", err)
-		return "", err
-	}
-	if err := out.Sync(); err != nil {
-		logrus.Errorf("sync file error=%v", err)
+	h := e2http.Builder(ctx).URL(url).ErrorOnNon2xx().
+		DownloadProgress(func(p e2http.Progress) {
+			logrus.Debugf("download %s %d/%d bytes", fileName, p.Done, p.Total)
+		}).
+		DownloadToFile(zipFile).Do()
+	if err := h.Err(); err != nil {
+		logrus.Errorf("download file error=%v", err)
 		return "", err
 	}
 